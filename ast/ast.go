@@ -241,6 +241,7 @@ type (
 		NamePos token.Pos // identifier position
 		Name    string    // identifier name
 		Global  bool      // indicates variable is a global
+		Default bool      // indicates variable is only set if undefined
 		Obj     *Object   // denoted object; or nil
 	}
 
@@ -272,7 +273,17 @@ type (
 		Value    []Expr
 		Paren    bool      // list is wrapped in parenthesis
 		Comma    bool      // record if list was comma delimited
+		Slash    bool      // record if list was slash delimited
+		Bracket  bool      // list is wrapped in square brackets
 		EndPos   token.Pos // end of list
+		Keywords *MapLit   // keywords passed to $args..., or nil
+	}
+
+	// A MapLit node represents a Sass map ie. (key: value, key2: value2)
+	MapLit struct {
+		Lparen token.Pos       // position of "("
+		Elts   []*KeyValueExpr // key value pairs in order of declaration
+		Rparen token.Pos       // position of ")"
 	}
 
 	// A FuncLit node represents a function literal.
@@ -298,6 +309,15 @@ type (
 		Obj    *Object
 	}
 
+	// A MediaFeature node represents a feature of a media query
+	// ie. (min-width: $a + 1px)
+	MediaFeature struct {
+		Lparen token.Pos // position of "("
+		Name   Expr      // feature name or the raw text of a range
+		Value  Expr      // value after the colon; or nil
+		Rparen token.Pos // position of ")"
+	}
+
 	// A ParenExpr node represents a parenthesized expression.
 	ParenExpr struct {
 		Lparen token.Pos // position of "("
@@ -470,6 +490,7 @@ func (x *Interp) Pos() token.Pos   { return x.Lbrace }
 func (x *Ellipsis) Pos() token.Pos { return x.Ellipsis }
 func (x *BasicLit) Pos() token.Pos { return x.ValuePos }
 func (x *ListLit) Pos() token.Pos  { return x.ValuePos }
+func (x *MapLit) Pos() token.Pos   { return x.Lparen }
 func (x *FuncLit) Pos() token.Pos  { return x.Type.Pos() }
 func (x *CompositeLit) Pos() token.Pos {
 	if x.Type != nil {
@@ -480,6 +501,7 @@ func (x *CompositeLit) Pos() token.Pos {
 
 func (x *StringExpr) Pos() token.Pos     { return x.Lquote }
 func (x *ParenExpr) Pos() token.Pos      { return x.Lparen }
+func (x *MediaFeature) Pos() token.Pos   { return x.Lparen }
 func (x *SelectorExpr) Pos() token.Pos   { return x.X.Pos() }
 func (x *IndexExpr) Pos() token.Pos      { return x.X.Pos() }
 func (x *SliceExpr) Pos() token.Pos      { return x.X.Pos() }
@@ -516,10 +538,12 @@ func (x *Ellipsis) End() token.Pos {
 }
 func (x *BasicLit) End() token.Pos       { return token.Pos(int(x.ValuePos) + len(x.Value)) }
 func (x *ListLit) End() token.Pos        { return x.EndPos }
+func (x *MapLit) End() token.Pos         { return x.Rparen + 1 }
 func (x *FuncLit) End() token.Pos        { return x.Body.End() }
 func (x *CompositeLit) End() token.Pos   { return x.Rbrace + 1 }
 func (x *StringExpr) End() token.Pos     { return x.Rquote + 1 }
 func (x *ParenExpr) End() token.Pos      { return x.Rparen + 1 }
+func (x *MediaFeature) End() token.Pos   { return x.Rparen + 1 }
 func (x *SelectorExpr) End() token.Pos   { return x.Sel.End() }
 func (x *IndexExpr) End() token.Pos      { return x.Rbrack + 1 }
 func (x *SliceExpr) End() token.Pos      { return x.Rbrack + 1 }
@@ -551,10 +575,12 @@ func (*Interp) exprNode()         {}
 func (*Ellipsis) exprNode()       {}
 func (*BasicLit) exprNode()       {}
 func (*ListLit) exprNode()        {}
+func (*MapLit) exprNode()         {}
 func (*FuncLit) exprNode()        {}
 func (*CompositeLit) exprNode()   {}
 func (*StringExpr) exprNode()     {}
 func (*ParenExpr) exprNode()      {}
+func (*MediaFeature) exprNode()   {}
 func (*SelectorExpr) exprNode()   {}
 func (*IndexExpr) exprNode()      {}
 func (*SliceExpr) exprNode()      {}
@@ -579,7 +605,7 @@ func (*ChanType) exprNode()      {}
 // Useful for ASTs generated by code other than the Go parser.
 //
 func NewIdent(name string) *Ident {
-	return &Ident{token.NoPos, name, false, nil}
+	return &Ident{token.NoPos, name, false, false, nil}
 }
 
 // IsExported reports whether name is an exported Go symbol
@@ -755,13 +781,21 @@ type (
 		Body   *BlockStmt // CommClauses only
 	}
 
-	// A ForStmt represents a for statement.
+	// A ForStmt represents @for $i from 1 through|to 3
 	ForStmt struct {
-		For  token.Pos // position of "for" keyword
-		Init Stmt      // initialization statement; or nil
-		Cond Expr      // condition; or nil
-		Post Stmt      // post iteration statement; or nil
-		Body *BlockStmt
+		For     token.Pos // position of @for
+		X       *Ident    // iterator
+		From    Expr      // start of the range
+		To      Expr      // end of the range
+		Through bool      // if set, To is included in the range
+		Body    *BlockStmt
+	}
+
+	// A WhileStmt represents @while $i > 0
+	WhileStmt struct {
+		While token.Pos // position of @while
+		Cond  Expr      // condition checked before every iteration
+		Body  *BlockStmt
 	}
 
 	// A RangeStmt represents a for statement with a range clause.
@@ -780,7 +814,8 @@ type (
 		Resolved *BasicLit // Resolved Selector to a token.STRING
 		NamePos  token.Pos
 		Names    []*Ident
-		Sel      Expr // parsed selector ast Tree
+		Sel      Expr   // parsed selector ast Tree
+		Raw      []Expr // selector before interpolation was merged; or nil
 		Doc      *CommentGroup
 		Body     *BlockStmt
 		Parent   *SelStmt
@@ -792,6 +827,7 @@ type (
 	EachStmt struct {
 		Each  token.Pos // position of @each
 		X     *Ident    // iterator
+		Vars  []*Ident  // additional iterators ie. @each $k, $v in; or nil
 		Range []Expr
 		Body  *BlockStmt
 		List  []Expr // List of values for the iterator
@@ -803,11 +839,48 @@ type (
 		Spec *IncludeSpec
 	}
 
-	// A MediaStmt wrapes a MediaSpec
+	// A MediaStmt represents @media
 	MediaStmt struct {
-		Name  *Ident
-		Query *BasicLit
-		Body  *BlockStmt
+		Name    *Ident
+		Params  []Expr        // query as parsed ie. words and features
+		Query   *BasicLit     // resolved query; or nil
+		Queries []*MediaQuery // Query merged with the enclosing @media
+		Sel     *BasicLit     // selector of rules in Body; or nil
+		Body    *BlockStmt
+	}
+
+	// An ExtendStmt represents @extend
+	ExtendStmt struct {
+		Extend   token.Pos // position of @extend
+		Sel      *BasicLit // target selector
+		Optional bool      // if set, !optional was found
+	}
+
+	// An AtRootStmt represents @at-root (without: media) { ... }
+	AtRootStmt struct {
+		AtRoot token.Pos  // position of @at-root
+		With   bool       // if set, Rules are kept instead of excluded
+		Rules  []string   // rule, media, supports or all
+		Sel    *BasicLit  // selector of rules in Body; or nil
+		Body   *BlockStmt // a selector after @at-root is the only stmt
+	}
+
+	// A DirectiveStmt represents a CSS at-rule passed through to the
+	// output ie. @font-face { ... } or @charset "UTF-8";
+	DirectiveStmt struct {
+		At      token.Pos  // position of Name or keyframe selector
+		Name    string     // ie. @keyframes, empty for keyframe blocks
+		Params  []Expr     // prelude before interpolation was merged
+		Prelude *BasicLit  // prelude after interpolation; or nil
+		Sel     *BasicLit  // selector of rules in Body; or nil
+		Body    *BlockStmt // or nil
+	}
+
+	// A DebugStmt represents @debug, @warn or @error
+	DebugStmt struct {
+		TokPos token.Pos   // position of Tok
+		Tok    token.Token // DEBUG, WARN or ERROR
+		X      Expr        // message
 	}
 )
 
@@ -834,12 +907,16 @@ func (s *TypeSwitchStmt) Pos() token.Pos { return s.Switch }
 func (s *CommClause) Pos() token.Pos     { return s.Case }
 func (s *SelectStmt) Pos() token.Pos     { return s.Select }
 func (s *ForStmt) Pos() token.Pos        { return s.For }
+func (s *WhileStmt) Pos() token.Pos      { return s.While }
 func (s *RangeStmt) Pos() token.Pos      { return s.For }
 
 func (s *SelStmt) Pos() token.Pos     { return s.NamePos }
 func (s *IncludeStmt) Pos() token.Pos { return s.Spec.Pos() }
 func (s *MediaStmt) Pos() token.Pos   { return s.Name.Pos() }
 func (s *EachStmt) Pos() token.Pos    { return s.Each }
+func (s *ExtendStmt) Pos() token.Pos  { return s.Extend }
+func (s *AtRootStmt) Pos() token.Pos  { return s.AtRoot }
+func (s *DebugStmt) Pos() token.Pos   { return s.TokPos }
 func (s *BadStmt) End() token.Pos     { return s.To }
 func (s *DeclStmt) End() token.Pos    { return s.Decl.End() }
 func (s *EmptyStmt) End() token.Pos {
@@ -893,12 +970,27 @@ func (s *CommClause) End() token.Pos {
 }
 func (s *SelectStmt) End() token.Pos { return s.Body.End() }
 func (s *ForStmt) End() token.Pos    { return s.Body.End() }
+func (s *WhileStmt) End() token.Pos  { return s.Body.End() }
 func (s *RangeStmt) End() token.Pos  { return s.Body.End() }
 
 func (s *SelStmt) End() token.Pos     { return s.Body.End() }
 func (s *IncludeStmt) End() token.Pos { return s.Spec.End() }
 func (s *MediaStmt) End() token.Pos   { return s.Body.End() }
 func (s *EachStmt) End() token.Pos    { return s.Body.End() }
+func (s *ExtendStmt) End() token.Pos  { return s.Sel.End() }
+func (s *AtRootStmt) End() token.Pos  { return s.Body.End() }
+func (s *DebugStmt) End() token.Pos   { return s.X.End() }
+
+func (s *DirectiveStmt) Pos() token.Pos { return s.At }
+func (s *DirectiveStmt) End() token.Pos {
+	switch {
+	case s.Body != nil:
+		return s.Body.End()
+	case s.Prelude != nil:
+		return s.Prelude.End()
+	}
+	return token.Pos(int(s.At) + len(s.Name))
+}
 
 // stmtNode() ensures that only statement nodes can be
 // assigned to a Stmt.
@@ -924,11 +1016,30 @@ func (*TypeSwitchStmt) stmtNode() {}
 func (*CommClause) stmtNode()     {}
 func (*SelectStmt) stmtNode()     {}
 func (*ForStmt) stmtNode()        {}
+func (*WhileStmt) stmtNode()      {}
 func (*RangeStmt) stmtNode()      {}
 func (*SelStmt) stmtNode()        {}
 func (*EachStmt) stmtNode()       {}
 func (*IncludeStmt) stmtNode()    {}
 func (*MediaStmt) stmtNode()      {}
+func (*AtRootStmt) stmtNode()     {}
+func (*ExtendStmt) stmtNode()     {}
+func (*DebugStmt) stmtNode()      {}
+func (*DirectiveStmt) stmtNode()  {}
+
+// Excludes reports whether the body of @at-root escapes name, ie. rule
+// or media. Without a query, only the rule is excluded.
+func (s *AtRootStmt) Excludes(name string) bool {
+	if len(s.Rules) == 0 {
+		return name == "rule"
+	}
+	for _, rule := range s.Rules {
+		if rule == name || rule == "all" {
+			return !s.With
+		}
+	}
+	return s.With
+}
 
 // ----------------------------------------------------------------------------
 // Declarations
@@ -943,13 +1054,35 @@ type (
 		specNode()
 	}
 
-	// An ImportSpec node represents a single package import.
+	// An ImportSpec node represents a single import path. Plain CSS
+	// imports are printed instead of inlined.
 	ImportSpec struct {
 		Doc     *CommentGroup // associated documentation; or nil
 		Name    *Ident        // local package name (including "."); or nil
 		Path    *BasicLit     // import path
 		Comment *CommentGroup // line comments; or nil
 		EndPos  token.Pos     // end of spec (overrides Path.Pos if nonzero)
+		Plain   bool          // plain CSS import ie. foo.css or url(foo)
+		Media   *BasicLit     // media query of a plain import; or nil
+	}
+
+	// A UseSpec node represents a module loaded by @use.
+	UseSpec struct {
+		Use    token.Pos       // position of "@use"
+		Path   *BasicLit       // module path
+		Name   string          // namespace of the members; "*" for none
+		Config []*KeyValueExpr // variables configured by with (...)
+		EndPos token.Pos       // end of spec
+	}
+
+	// A ForwardSpec node represents a module forwarded by @forward.
+	ForwardSpec struct {
+		Forward token.Pos // position of "@forward"
+		Path    *BasicLit // module path
+		Prefix  string    // prefix added to the members ie. as prefix-*
+		Show    []string  // members forwarded; or nil for all of them
+		Hide    []string  // members not forwarded
+		EndPos  token.Pos // end of spec
 	}
 
 	// A ValueSpec node represents a constant or variable declaration
@@ -1015,6 +1148,9 @@ func (s *ImportSpec) Pos() token.Pos {
 	return s.Path.Pos()
 }
 
+func (s *UseSpec) Pos() token.Pos     { return s.Use }
+func (s *ForwardSpec) Pos() token.Pos { return s.Forward }
+
 func (s *ValueSpec) Pos() token.Pos     { return s.Names[0].Pos() }
 func (s *PropValueSpec) Pos() token.Pos { return s.Name.Pos() }
 func (s *TypeSpec) Pos() token.Pos      { return s.Name.Pos() }
@@ -1038,6 +1174,9 @@ func (s *ImportSpec) End() token.Pos {
 	return s.Path.End()
 }
 
+func (s *UseSpec) End() token.Pos     { return s.EndPos }
+func (s *ForwardSpec) End() token.Pos { return s.EndPos }
+
 func (s *PropValueSpec) End() token.Pos {
 	return s.Name.End()
 }
@@ -1064,6 +1203,9 @@ func (*SelSpec) specNode()       {}
 func (*IncludeSpec) specNode()   {}
 func (*RuleSpec) specNode()      {}
 
+func (*UseSpec) specNode()     {}
+func (*ForwardSpec) specNode() {}
+
 // A declaration is represented by one of the following declaration nodes.
 //
 type (
@@ -1126,6 +1268,41 @@ type (
 	EachDecl struct {
 		*EachStmt
 	}
+
+	// A ForDecl node represents a @for declaration outside
+	// of selectors
+	ForDecl struct {
+		*ForStmt
+	}
+
+	// A WhileDecl node represents a @while declaration outside
+	// of selectors
+	WhileDecl struct {
+		*WhileStmt
+	}
+
+	// A DebugDecl node represents @debug, @warn or @error outside
+	// of selectors
+	DebugDecl struct {
+		*DebugStmt
+	}
+
+	// A MediaDecl node represents @media outside of selectors
+	MediaDecl struct {
+		*MediaStmt
+	}
+
+	// A DirectiveDecl node represents a CSS at-rule outside of
+	// selectors
+	DirectiveDecl struct {
+		*DirectiveStmt
+	}
+
+	// An AtRootDecl node represents @at-root that left a top level
+	// @media
+	AtRootDecl struct {
+		*AtRootStmt
+	}
 )
 
 // Pos and End implementations for declaration nodes.
@@ -1160,11 +1337,18 @@ func (d *FuncDecl) End() token.Pos {
 // declNode() ensures that only declaration nodes can be
 // assigned to a Decl.
 //
-func (*BadDecl) declNode()  {}
-func (*GenDecl) declNode()  {}
-func (*FuncDecl) declNode() {}
-func (*SelDecl) declNode()  {}
-func (*IfDecl) declNode()   {}
+func (*BadDecl) declNode()       {}
+func (*GenDecl) declNode()       {}
+func (*FuncDecl) declNode()      {}
+func (*SelDecl) declNode()       {}
+func (*IfDecl) declNode()        {}
+func (*EachDecl) declNode()      {}
+func (*ForDecl) declNode()       {}
+func (*WhileDecl) declNode()     {}
+func (*DebugDecl) declNode()     {}
+func (*DirectiveDecl) declNode() {}
+func (*MediaDecl) declNode()     {}
+func (*AtRootDecl) declNode()    {}
 
 // ----------------------------------------------------------------------------
 // Files and packages
@@ -1184,6 +1368,8 @@ type File struct {
 	Imports    []*ImportSpec   // imports in this file
 	Unresolved []*Ident        // unresolved identifiers in this file
 	Comments   []*CommentGroup // list of all comments in the source file
+	Deps       []string        // files read by imports
+	Globs      []string        // patterns of glob imports
 }
 
 func (f *File) Pos() token.Pos { return f.Package }
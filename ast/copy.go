@@ -53,6 +53,7 @@ func StmtCopy(in Stmt) (out Stmt) {
 			Body:    StmtCopy(v.Body).(*BlockStmt),
 			// SelDecl: DeclCopy(v.SelDecl).(*SelDecl),
 			Sel: ExprCopy(v.Sel),
+			Raw: ExprsCopy(v.Raw),
 		}
 		if v.Parent != nil {
 			stmt.Parent = &SelStmt{
@@ -77,10 +78,67 @@ func StmtCopy(in Stmt) (out Stmt) {
 	case *EachStmt:
 		stmt := &EachStmt{}
 		stmt.X = v.X
+		stmt.Vars = v.Vars
 		stmt.Body = StmtCopy(v.Body).(*BlockStmt)
 		stmt.List = ExprsCopy(v.List)
 		stmt.Each = v.Each
 		out = stmt
+	case *ForStmt:
+		out = &ForStmt{
+			For:     v.For,
+			X:       v.X,
+			From:    ExprCopy(v.From),
+			To:      ExprCopy(v.To),
+			Through: v.Through,
+			Body:    StmtCopy(v.Body).(*BlockStmt),
+		}
+	case *WhileStmt:
+		out = &WhileStmt{
+			While: v.While,
+			Cond:  ExprCopy(v.Cond),
+			Body:  StmtCopy(v.Body).(*BlockStmt),
+		}
+	case *AtRootStmt:
+		out = &AtRootStmt{
+			AtRoot: v.AtRoot,
+			With:   v.With,
+			Rules:  v.Rules,
+			Sel:    v.Sel,
+			Body:   StmtCopy(v.Body).(*BlockStmt),
+		}
+	case *MediaStmt:
+		out = &MediaStmt{
+			Name:    v.Name,
+			Params:  ExprsCopy(v.Params),
+			Query:   v.Query,
+			Queries: v.Queries,
+			Sel:     v.Sel,
+			Body:    StmtCopy(v.Body).(*BlockStmt),
+		}
+	case *DirectiveStmt:
+		d := &DirectiveStmt{
+			At:      v.At,
+			Name:    v.Name,
+			Params:  ExprsCopy(v.Params),
+			Prelude: v.Prelude,
+			Sel:     v.Sel,
+		}
+		if v.Body != nil {
+			d.Body = StmtCopy(v.Body).(*BlockStmt)
+		}
+		out = d
+	case *DebugStmt:
+		out = &DebugStmt{
+			TokPos: v.TokPos,
+			Tok:    v.Tok,
+			X:      ExprCopy(v.X),
+		}
+	case *ExtendStmt:
+		out = &ExtendStmt{
+			Extend:   v.Extend,
+			Sel:      ExprCopy(v.Sel).(*BasicLit),
+			Optional: v.Optional,
+		}
 	case *EmptyStmt:
 	default:
 		log.Fatalf("unsupported stmt copy %T: % #v\n", v, v)
@@ -136,6 +194,23 @@ func ExprCopy(in Expr) (out Expr) {
 			Args:   ExprsCopy(expr.Args),
 			Fun:    ExprCopy(expr.Fun),
 		}
+	case *StringExpr:
+		out = &StringExpr{
+			Kind:   expr.Kind,
+			Lquote: expr.Lquote,
+			List:   ExprsCopy(expr.List),
+			Rquote: expr.Rquote,
+		}
+	case *MediaFeature:
+		f := &MediaFeature{
+			Lparen: expr.Lparen,
+			Name:   ExprCopy(expr.Name),
+			Rparen: expr.Rparen,
+		}
+		if expr.Value != nil {
+			f.Value = ExprCopy(expr.Value)
+		}
+		out = f
 	case *KeyValueExpr:
 		kv := &KeyValueExpr{}
 		kv.Colon = expr.Colon
@@ -145,10 +220,25 @@ func ExprCopy(in Expr) (out Expr) {
 	case *ListLit:
 		lit := &ListLit{
 			Comma:    expr.Comma,
+			Slash:    expr.Slash,
+			Bracket:  expr.Bracket,
 			ValuePos: expr.Pos(),
 			EndPos:   expr.End(),
 		}
 		lit.Value = ExprsCopy(expr.Value)
+		if expr.Keywords != nil {
+			lit.Keywords = ExprCopy(expr.Keywords).(*MapLit)
+		}
+		out = lit
+	case *MapLit:
+		lit := &MapLit{
+			Lparen: expr.Lparen,
+			Rparen: expr.Rparen,
+			Elts:   make([]*KeyValueExpr, len(expr.Elts)),
+		}
+		for i := range expr.Elts {
+			lit.Elts[i] = ExprCopy(expr.Elts[i]).(*KeyValueExpr)
+		}
 		out = lit
 	default:
 		panic(fmt.Errorf("unsupported expr copy: % #v\n", expr))
@@ -162,6 +252,7 @@ func IdentCopy(in *Ident) (out *Ident) {
 	out = NewIdent(in.Name)
 	out.NamePos = in.Pos()
 	out.Global = in.Global
+	out.Default = in.Default
 	return
 }
 
@@ -215,6 +306,11 @@ func SpecCopy(in Spec) (out Spec) {
 		}
 		spec.List = list
 		out = spec
+	case *ImportSpec:
+		// the imported declarations follow the spec, only the
+		// spec itself is copied
+		spec := *v
+		out = &spec
 	default:
 		out = v
 		log.Fatalf("unsupported spec copy %T: % #v\n", v, v)
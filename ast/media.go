@@ -0,0 +1,281 @@
+package ast
+
+import (
+	"errors"
+	"strings"
+)
+
+// A MediaQuery is a resolved query of @media
+// ie. only screen and (min-width: 100px)
+type MediaQuery struct {
+	Modifier string   // not or only; or empty
+	Type     string   // ie. screen; or empty
+	Features []string // ie. (min-width: 100px)
+}
+
+func (q *MediaQuery) String() string {
+	var ss []string
+	if len(q.Modifier) > 0 {
+		ss = append(ss, q.Modifier)
+	}
+	if len(q.Type) > 0 {
+		ss = append(ss, q.Type)
+	}
+	if len(ss) > 0 && len(q.Features) > 0 {
+		ss = append(ss, "and")
+	}
+	for i, f := range q.Features {
+		if i > 0 {
+			ss = append(ss, "and")
+		}
+		ss = append(ss, f)
+	}
+	return strings.Join(ss, " ")
+}
+
+// matchesAll reports whether the query applies to every media type
+func (q *MediaQuery) matchesAll() bool {
+	return len(q.Type) == 0 || strings.EqualFold(q.Type, "all")
+}
+
+// MediaQueries joins queries as they are printed
+func MediaQueries(queries []*MediaQuery) string {
+	ss := make([]string, len(queries))
+	for i, q := range queries {
+		ss[i] = q.String()
+	}
+	return strings.Join(ss, ", ")
+}
+
+var errMediaQuery = errors.New("expected media query")
+
+// ParseMediaQueries reads the comma separated queries in s
+// ie. screen and (color), print
+func ParseMediaQueries(s string) ([]*MediaQuery, error) {
+	var queries []*MediaQuery
+	for _, part := range splitMedia(s, ',') {
+		q, err := parseMediaQuery(part)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+// splitMedia splits s by sep outside of parentheses
+func splitMedia(s string, sep rune) []string {
+	var ss []string
+	var depth, offs int
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				ss = append(ss, s[offs:i])
+				offs = i + 1
+			}
+		}
+	}
+	return append(ss, s[offs:])
+}
+
+// mediaWords splits a query into words and parenthesized features
+func mediaWords(s string) []string {
+	var words []string
+	var depth int
+	offs := -1
+	for i, r := range s {
+		switch {
+		case r == '(':
+			if depth == 0 && offs >= 0 {
+				words = append(words, s[offs:i])
+				offs = -1
+			}
+			if offs < 0 {
+				offs = i
+			}
+			depth++
+		case r == ')':
+			depth--
+			if depth == 0 {
+				words = append(words, s[offs:i+1])
+				offs = -1
+			}
+		case depth > 0:
+		case r == ' ' || r == '\t' || r == '\n':
+			if offs >= 0 {
+				words = append(words, s[offs:i])
+				offs = -1
+			}
+		default:
+			if offs < 0 {
+				offs = i
+			}
+		}
+	}
+	if offs >= 0 {
+		words = append(words, s[offs:])
+	}
+	return words
+}
+
+func parseMediaQuery(s string) (*MediaQuery, error) {
+	words := mediaWords(s)
+	if len(words) == 0 {
+		return nil, errMediaQuery
+	}
+	q := &MediaQuery{}
+	if !strings.HasPrefix(words[0], "(") {
+		switch strings.ToLower(words[0]) {
+		case "not", "only":
+			if len(words) < 2 || strings.HasPrefix(words[1], "(") {
+				return nil, errMediaQuery
+			}
+			q.Modifier = words[0]
+			words = words[1:]
+		}
+		q.Type = words[0]
+		words = words[1:]
+		if len(words) > 0 {
+			if !strings.EqualFold(words[0], "and") {
+				return nil, errors.New(`expected "and"`)
+			}
+			words = words[1:]
+			if len(words) == 0 {
+				return nil, errMediaQuery
+			}
+		}
+	}
+	for i, w := range words {
+		if i%2 == 1 {
+			if !strings.EqualFold(w, "and") {
+				return nil, errors.New(`expected "and"`)
+			}
+			continue
+		}
+		if !strings.HasPrefix(w, "(") {
+			return nil, errors.New(`expected "("`)
+		}
+		q.Features = append(q.Features, w)
+	}
+	if len(words)%2 == 0 && len(words) > 0 {
+		return nil, errMediaQuery
+	}
+	return q, nil
+}
+
+// MergeMediaQueries combines the queries of nested @media, a query
+// of the result matches when one of outer and one of inner match.
+// Combinations that never match are dropped. It reports false when
+// a combination can not be represented in CSS.
+func MergeMediaQueries(outer, inner []*MediaQuery) ([]*MediaQuery, bool) {
+	var queries []*MediaQuery
+	for _, q1 := range outer {
+		for _, q2 := range inner {
+			q, ok := mergeMedia(q1, q2)
+			if !ok {
+				return nil, false
+			}
+			if q != nil {
+				queries = append(queries, q)
+			}
+		}
+	}
+	return queries, true
+}
+
+// mergeMedia returns the query matched by both q1 and q2, nil if
+// none will.
+func mergeMedia(q1, q2 *MediaQuery) (*MediaQuery, bool) {
+	mod1, type1 := strings.ToLower(q1.Modifier), strings.ToLower(q1.Type)
+	mod2, type2 := strings.ToLower(q2.Modifier), strings.ToLower(q2.Type)
+	features := append(append([]string{}, q1.Features...), q2.Features...)
+
+	if len(type1) == 0 && len(type2) == 0 {
+		return &MediaQuery{Features: features}, true
+	}
+
+	not1, not2 := mod1 == "not", mod2 == "not"
+	switch {
+	case not1 != not2:
+		if type1 == type2 {
+			neg, pos := q1.Features, q2.Features
+			if not2 {
+				neg, pos = pos, neg
+			}
+			// not screen and (color) never matches
+			// screen and (color) and (grid)
+			if containsAll(pos, neg) {
+				return nil, true
+			}
+			return nil, false
+		}
+		if q1.matchesAll() || q2.matchesAll() {
+			return nil, false
+		}
+		// not print and screen is screen
+		if not1 {
+			return copyMedia(q2), true
+		}
+		return copyMedia(q1), true
+	case not1:
+		// there is no way to write neither screen nor print
+		if type1 != type2 {
+			return nil, false
+		}
+		more, fewer := q1, q2
+		if len(q2.Features) > len(q1.Features) {
+			more, fewer = q2, q1
+		}
+		if !containsAll(more.Features, fewer.Features) {
+			return nil, false
+		}
+		return copyMedia(more), true
+	case q1.matchesAll():
+		q := &MediaQuery{Modifier: q2.Modifier, Type: q2.Type, Features: features}
+		// a query without type targets every browser
+		if q2.matchesAll() && len(type1) == 0 {
+			q.Type = ""
+		}
+		return q, true
+	case q2.matchesAll():
+		return &MediaQuery{Modifier: q1.Modifier, Type: q1.Type, Features: features}, true
+	case type1 != type2:
+		// print and screen never matches
+		return nil, true
+	}
+	q := &MediaQuery{Modifier: q1.Modifier, Type: q1.Type, Features: features}
+	if len(q.Modifier) == 0 {
+		q.Modifier = q2.Modifier
+	}
+	return q, true
+}
+
+func copyMedia(q *MediaQuery) *MediaQuery {
+	return &MediaQuery{
+		Modifier: q.Modifier,
+		Type:     q.Type,
+		Features: append([]string{}, q.Features...),
+	}
+}
+
+// containsAll reports whether all of sub are found in list
+func containsAll(list, sub []string) bool {
+	for _, s := range sub {
+		found := false
+		for _, l := range list {
+			if l == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
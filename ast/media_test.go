@@ -0,0 +1,63 @@
+package ast
+
+import "testing"
+
+func TestParseMediaQueries(t *testing.T) {
+	table := []struct {
+		in, out string
+	}{
+		{"print", "print"},
+		{"only  screen and (color)", "only screen and (color)"},
+		{"(min-width: 1px) and (max-width: 2px)", "(min-width: 1px) and (max-width: 2px)"},
+		{"not print, screen", "not print, screen"},
+	}
+	for _, tt := range table {
+		qs, err := ParseMediaQueries(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := MediaQueries(qs); s != tt.out {
+			t.Errorf("got: %s wanted: %s", s, tt.out)
+		}
+	}
+
+	for _, in := range []string{"", "print screen", "print and"} {
+		if _, err := ParseMediaQueries(in); err == nil {
+			t.Errorf("expected error parsing: %q", in)
+		}
+	}
+}
+
+func TestMergeMediaQueries(t *testing.T) {
+	table := []struct {
+		outer, inner string
+		out          string
+		ok           bool
+	}{
+		{"print", "(color)", "print and (color)", true},
+		{"screen, print", "(color)", "screen and (color), print and (color)", true},
+		{"screen", "only screen", "only screen", true},
+		{"screen", "print", "", true},
+		{"not print", "print", "", true},
+		{"(min-width: 1px)", "(max-width: 2px)", "(min-width: 1px) and (max-width: 2px)", true},
+		{"not screen", "not print", "", false},
+	}
+	for _, tt := range table {
+		outer, err := ParseMediaQueries(tt.outer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inner, err := ParseMediaQueries(tt.inner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qs, ok := MergeMediaQueries(outer, inner)
+		if ok != tt.ok {
+			t.Errorf("%s + %s got: %t wanted: %t", tt.outer, tt.inner, ok, tt.ok)
+			continue
+		}
+		if s := MediaQueries(qs); s != tt.out {
+			t.Errorf("%s + %s got: %q wanted: %q", tt.outer, tt.inner, s, tt.out)
+		}
+	}
+}
@@ -61,7 +61,7 @@ func (s *Scope) Insert(obj *Object, global bool) (alt *Object) {
 	// Global insanity
 	if assign, ok := obj.Decl.(*AssignStmt); ok {
 
-		if list, isList := assign.Rhs[0].(*ListLit); isList {
+		if list, isList := assign.Rhs[0].(*ListLit); isList && len(list.Value) > 0 {
 			l := len(list.Value)
 			if lit, ok := list.Value[l-1].(*BasicLit); ok {
 				if lit.Value == "!global" {
@@ -295,6 +295,10 @@ func NewNum(lit *ast.BasicLit) (*Num, error) {
 	// TODO: scanner should remove unit
 	kind := lit.Kind
 	val = strings.TrimSuffix(lit.Value, token.Tokens[kind])
+	if kind == token.UPCT {
+		// percentages are written 10%
+		val = strings.TrimSuffix(lit.Value, "%")
+	}
 	dec, err := decimal.NewFromString(val)
 	return &Num{dec: dec, Unit: unitLookup(kind)}, err
 }
@@ -336,6 +340,33 @@ func (z *Num) Convert(src *Num) *Num {
 	}
 }
 
+// kind groups the units that convert to each other
+func (u Unit) kind() string {
+	switch {
+	case IN <= u && u <= PT:
+		return "length"
+	case DEG <= u && u <= TURN:
+		return "angle"
+	}
+	return u.String()
+}
+
+// Cmp compares z to y converted to the unit of z. Unitless numbers
+// compare to any unit, it reports false for units that do not convert
+// to each other ie. px and deg.
+func (z *Num) Cmp(y *Num) (int, bool) {
+	switch {
+	case z.Unit == y.Unit || z.Unit == NOUNIT || y.Unit == NOUNIT:
+		return z.dec.Cmp(y.dec), true
+	case z.Unit == INVALID || y.Unit == INVALID:
+		return 0, false
+	case z.Unit != NOUNIT && y.Unit != NOUNIT &&
+		z.Unit.kind() != y.Unit.kind():
+		return 0, false
+	}
+	return z.dec.Cmp(z.Convert(y).dec), true
+}
+
 // Op returns the sum of x and y using the specified Op
 func (z *Num) Op(op token.Token, x, y *Num) *Num {
 	switch op {
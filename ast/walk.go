@@ -112,6 +112,11 @@ func Walk(v Visitor, node Node) {
 	case *ListLit:
 		walkExprList(v, n.Value)
 
+	case *MapLit:
+		for _, x := range n.Elts {
+			Walk(v, x)
+		}
+
 	case *CompositeLit:
 		if n.Type != nil {
 			Walk(v, n.Type)
@@ -121,6 +126,12 @@ func Walk(v Visitor, node Node) {
 	case *ParenExpr:
 		Walk(v, n.X)
 
+	case *MediaFeature:
+		Walk(v, n.Name)
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+
 	case *SelectorExpr:
 		Walk(v, n.X)
 		Walk(v, n.Sel)
@@ -151,6 +162,20 @@ func Walk(v Visitor, node Node) {
 	case *MediaStmt:
 		Walk(v, n.Body)
 
+	case *AtRootStmt:
+		Walk(v, n.Body)
+
+	case *DirectiveStmt:
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *ExtendStmt:
+		// nothing to do
+
+	case *DebugStmt:
+		// the message is printed by the visitor
+
 	case *CallExpr:
 		Walk(v, n.Fun)
 		walkExprList(v, n.Args)
@@ -247,6 +272,20 @@ func Walk(v Visitor, node Node) {
 
 	case *IfDecl:
 		Walk(v, n.IfStmt)
+	case *EachDecl:
+		Walk(v, n.EachStmt)
+	case *ForDecl:
+		Walk(v, n.ForStmt)
+	case *WhileDecl:
+		Walk(v, n.WhileStmt)
+	case *DebugDecl:
+		Walk(v, n.DebugStmt)
+	case *DirectiveDecl:
+		Walk(v, n.DirectiveStmt)
+	case *MediaDecl:
+		Walk(v, n.MediaStmt)
+	case *AtRootDecl:
+		Walk(v, n.AtRootStmt)
 	case *IfStmt:
 		if n.Init != nil {
 			Walk(v, n.Init)
@@ -287,15 +326,9 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Body)
 
 	case *ForStmt:
-		if n.Init != nil {
-			Walk(v, n.Init)
-		}
-		if n.Cond != nil {
-			Walk(v, n.Cond)
-		}
-		if n.Post != nil {
-			Walk(v, n.Post)
-		}
+		Walk(v, n.Body)
+
+	case *WhileStmt:
 		Walk(v, n.Body)
 
 	case *RangeStmt:
@@ -317,10 +350,22 @@ func Walk(v Visitor, node Node) {
 			Walk(v, n.Name)
 		}
 		Walk(v, n.Path)
+		if n.Media != nil {
+			Walk(v, n.Media)
+		}
 		if n.Comment != nil {
 			Walk(v, n.Comment)
 		}
 
+	case *UseSpec:
+		Walk(v, n.Path)
+		for _, kv := range n.Config {
+			Walk(v, kv)
+		}
+
+	case *ForwardSpec:
+		Walk(v, n.Path)
+
 	case *RuleSpec:
 		if n.Comment != nil {
 			Walk(v, n.Comment)
@@ -6,11 +6,12 @@ import (
 
 	"github.com/wellington/sass/ast"
 	"github.com/wellington/sass/builtin"
+	"github.com/wellington/sass/calc"
 	"github.com/wellington/sass/token"
 )
 
 func init() {
-	builtin.Register("inspect($value)", inspect)
+	builtin.Reg("inspect($value)", inspect)
 	builtin.Register("unit($number)", unit)
 	builtin.Reg("type-of($value)", typeOf)
 }
@@ -39,11 +40,11 @@ func unit(call *ast.CallExpr, args ...*ast.BasicLit) (*ast.BasicLit, error) {
 	return lit, nil
 }
 
-func inspect(call *ast.CallExpr, args ...*ast.BasicLit) (*ast.BasicLit, error) {
+func inspect(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
 	if len(args) != 1 {
 		return nil, fmt.Errorf("wrong number of arguments (%d for 1) for 'inspect'", len(args))
 	}
-	return args[0], nil
+	return calc.Inspect(args[0])
 }
 
 func typeOf(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
@@ -59,12 +60,23 @@ func typeOf(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
 			lit.Value = "color"
 		case token.INT, token.FLOAT:
 			lit.Value = "number"
-		case token.STRING, token.QSSTRING, token.QSTRING:
+		case token.STRING:
+			switch v.Value {
+			case "true", "false":
+				lit.Value = "bool"
+			default:
+				lit.Value = "string"
+			}
+		case token.NULL:
+			lit.Value = "null"
+		case token.QSSTRING, token.QSTRING:
 			lit.Value = "string"
 		default:
 			lit.Kind = token.ILLEGAL
 		}
 		return lit, nil
+	case *ast.MapLit:
+		return &ast.BasicLit{Kind: token.STRING, Value: "map"}, nil
 	}
 	return nil, nil
 }
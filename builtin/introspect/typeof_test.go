@@ -33,3 +33,13 @@ func TestTypeOf(t *testing.T) {
 		t.Errorf("got: %s wanted: %s", lit.Value, e)
 	}
 }
+
+func TestTypeOf_map(t *testing.T) {
+	x, err := typeOf(&ast.CallExpr{}, &ast.MapLit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := "map"; x.(*ast.BasicLit).Value != e {
+		t.Errorf("got: %s wanted: %s", x.(*ast.BasicLit).Value, e)
+	}
+}
@@ -0,0 +1,204 @@
+package list
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/wellington/sass/ast"
+	"github.com/wellington/sass/builtin"
+	"github.com/wellington/sass/calc"
+	"github.com/wellington/sass/token"
+)
+
+func init() {
+	builtin.Reg("join($list1, $list2, $separator: auto, $bracketed: auto)", join)
+	builtin.Reg("append($list, $val, $separator: auto)", appendList)
+	builtin.Reg("index($list, $value)", indexOf)
+	builtin.Reg("list-separator($list)", listSeparator)
+	builtin.Reg("is-bracketed($list)", isBracketed)
+}
+
+// toList dereferences variables until a value is found. Values that
+// are not lists are treated as a list of one.
+func toList(x ast.Expr) *ast.ListLit {
+	for {
+		switch v := x.(type) {
+		case *ast.Ident:
+			if v.Obj == nil {
+				return single(v)
+			}
+			assign, ok := v.Obj.Decl.(*ast.AssignStmt)
+			if !ok {
+				return single(v)
+			}
+			x = assign.Rhs[0]
+		case *ast.CallExpr:
+			x = v.Resolved
+		case *ast.ListLit:
+			return v
+		case *ast.MapLit:
+			// maps are lists of key value pairs
+			list := &ast.ListLit{
+				ValuePos: v.Pos(),
+				EndPos:   v.End(),
+				Comma:    true,
+			}
+			for _, kv := range v.Elts {
+				list.Value = append(list.Value, &ast.ListLit{
+					ValuePos: kv.Pos(),
+					EndPos:   kv.End(),
+					Value:    []ast.Expr{kv.Key, kv.Value},
+				})
+			}
+			return list
+		default:
+			return single(v)
+		}
+	}
+}
+
+func single(x ast.Expr) *ast.ListLit {
+	return &ast.ListLit{
+		ValuePos: x.Pos(),
+		EndPos:   x.End(),
+		Value:    []ast.Expr{x},
+	}
+}
+
+func newList(call *ast.CallExpr, comma, slash, bracket bool) *ast.ListLit {
+	return &ast.ListLit{
+		ValuePos: call.Pos(),
+		EndPos:   call.End(),
+		Comma:    comma,
+		Slash:    slash,
+		Bracket:  bracket,
+	}
+}
+
+func toLit(x ast.Expr) (*ast.BasicLit, error) {
+	if ident, ok := x.(*ast.Ident); ok && ident.Obj == nil {
+		return &ast.BasicLit{
+			ValuePos: ident.Pos(),
+			Kind:     token.STRING,
+			Value:    ident.Name,
+		}, nil
+	}
+	return calc.Resolve(x, true)
+}
+
+// decided reports whether the separator of list is known, a single
+// value may be joined with any separator
+func decided(list *ast.ListLit) bool {
+	return len(list.Value) > 1 || list.Comma || list.Slash
+}
+
+// separator reads the $separator argument, auto uses the separator
+// of the first list that has one
+func separator(x ast.Expr, lists ...*ast.ListLit) (comma, slash bool, err error) {
+	lit, err := toLit(x)
+	if err != nil {
+		return false, false, err
+	}
+	switch lit.Value {
+	case "comma":
+		return true, false, nil
+	case "slash":
+		return false, true, nil
+	case "space":
+		return false, false, nil
+	case "auto":
+		for _, list := range lists {
+			if decided(list) {
+				return list.Comma, list.Slash, nil
+			}
+		}
+		return false, false, nil
+	}
+	return false, false, fmt.Errorf(`$separator: Must be "space", "comma", "slash", or "auto".`)
+}
+
+func join(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	l1, l2 := toList(args[0]), toList(args[1])
+	comma, slash, err := separator(args[2], l1, l2)
+	if err != nil {
+		return nil, err
+	}
+	lit, err := toLit(args[3])
+	if err != nil {
+		return nil, err
+	}
+	bracket := l1.Bracket
+	if lit.Value != "auto" {
+		bracket = calc.Truthy(lit)
+	}
+	out := newList(call, comma, slash, bracket)
+	out.Value = make([]ast.Expr, 0, len(l1.Value)+len(l2.Value))
+	out.Value = append(out.Value, l1.Value...)
+	out.Value = append(out.Value, l2.Value...)
+	return out, nil
+}
+
+func appendList(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	list := toList(args[0])
+	comma, slash, err := separator(args[2], list)
+	if err != nil {
+		return nil, err
+	}
+	out := newList(call, comma, slash, list.Bracket)
+	out.Value = make([]ast.Expr, 0, len(list.Value)+1)
+	out.Value = append(out.Value, list.Value...)
+	out.Value = append(out.Value, args[1])
+	return out, nil
+}
+
+// indexOf returns the position of $value in $list or null
+func indexOf(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	list := toList(args[0])
+	lit := &ast.BasicLit{
+		Kind:     token.NULL,
+		ValuePos: call.Pos(),
+		Value:    "null",
+	}
+	for i, x := range list.Value {
+		eq, err := calc.Resolve(&ast.BinaryExpr{
+			X:     x,
+			Op:    token.EQL,
+			Y:     args[1],
+			OpPos: call.Pos(),
+		}, true)
+		if err != nil {
+			return nil, err
+		}
+		if calc.Truthy(eq) {
+			lit.Kind = token.INT
+			lit.Value = strconv.Itoa(i + 1)
+			break
+		}
+	}
+	return lit, nil
+}
+
+func listSeparator(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	list := toList(args[0])
+	lit := &ast.BasicLit{
+		Kind:     token.STRING,
+		ValuePos: call.Pos(),
+		Value:    "space",
+	}
+	switch {
+	case list.Comma:
+		lit.Value = "comma"
+	case list.Slash:
+		lit.Value = "slash"
+	}
+	return lit, nil
+}
+
+func isBracketed(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	lit := &ast.BasicLit{
+		Kind:     token.STRING,
+		ValuePos: call.Pos(),
+		Value:    strconv.FormatBool(toList(args[0]).Bracket),
+	}
+	return lit, nil
+}
@@ -9,28 +9,49 @@ import (
 )
 
 func init() {
-	builtin.Reg("nth($list, $pos)", nth)
+	builtin.Reg("nth($list, $n)", nth)
+	builtin.Reg("set-nth($list, $n, $value)", setNth)
 }
 
-func nth(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
-	in := args[0]
-	x := args[1]
-	s := x.(*ast.BasicLit)
-	pos, err := strconv.Atoi(s.Value)
+// index converts the Sass index x to a position in list. Negative
+// indices count from the end of the list.
+func index(fn string, list *ast.ListLit, x ast.Expr) (int, error) {
+	lit, err := toLit(x)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-
-	list, ok := in.(*ast.ListLit)
-	if !ok {
-		list = &ast.ListLit{
-			Value: []ast.Expr{in},
-		}
+	pos, err := strconv.Atoi(lit.Value)
+	if err != nil {
+		return 0, fmt.Errorf("$n: %s is not an integer for `%s'", lit.Value, fn)
 	}
+	n := len(list.Value)
+	if pos == 0 || pos > n || pos < -n {
+		return 0, fmt.Errorf("index out of bounds for `%s($list, $n)` at %d", fn, x.Pos())
+	}
+	if pos < 0 {
+		return n + pos, nil
+	}
+	return pos - 1, nil
+}
 
-	if pos > len(list.Value) {
-		return nil, fmt.Errorf("index out of bounds for `nth($list, $n)` at %d", in.Pos())
+func nth(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	list := toList(args[0])
+	i, err := index("nth", list, args[1])
+	if err != nil {
+		return nil, err
 	}
+	return list.Value[i], nil
+}
 
-	return list.Value[pos-1], nil
+// setNth returns a copy of $list with the value at $n replaced
+func setNth(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	list := toList(args[0])
+	i, err := index("set-nth", list, args[1])
+	if err != nil {
+		return nil, err
+	}
+	out := newList(call, list.Comma, list.Slash, list.Bracket)
+	out.Value = append(out.Value, list.Value...)
+	out.Value[i] = args[2]
+	return out, nil
 }
@@ -0,0 +1,240 @@
+package maps
+
+import (
+	"fmt"
+
+	"github.com/wellington/sass/ast"
+	"github.com/wellington/sass/builtin"
+	"github.com/wellington/sass/calc"
+	"github.com/wellington/sass/token"
+)
+
+func init() {
+	builtin.Reg("map-get($map, $key)", mapGet)
+	builtin.Reg("map-merge($map1, $map2)", mapMerge)
+	builtin.Reg("map-remove($map, $key)", mapRemove)
+	builtin.Reg("map-keys($map)", mapKeys)
+	builtin.Reg("map-values($map)", mapValues)
+	builtin.Reg("map-has-key($map, $key)", mapHasKey)
+	builtin.Reg("keywords($args)", keywords)
+}
+
+// toMap dereferences variables until a map is found
+func toMap(fn string, x ast.Expr) (*ast.MapLit, error) {
+	for {
+		switch v := x.(type) {
+		case nil:
+			return nil, fmt.Errorf("$map: null is not a map for `%s'", fn)
+		case *ast.MapLit:
+			return v, nil
+		case *ast.Ident:
+			if v.Obj == nil {
+				return nil, fmt.Errorf("Undefined variable: \"%s\".", v.Name)
+			}
+			assign, ok := v.Obj.Decl.(*ast.AssignStmt)
+			if !ok {
+				return nil, fmt.Errorf("$map: %s is not a map for `%s'", v.Name, fn)
+			}
+			x = assign.Rhs[0]
+		case *ast.CallExpr:
+			x = v.Resolved
+		case *ast.ListLit:
+			// () is an empty map as well as an empty list
+			if len(v.Value) == 0 {
+				return &ast.MapLit{Lparen: v.Pos(), Rparen: v.End()}, nil
+			}
+			return nil, notMap(fn, v)
+		default:
+			return nil, notMap(fn, v)
+		}
+	}
+}
+
+func notMap(fn string, x ast.Expr) error {
+	lit, err := calc.Resolve(x, true)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("$map: %s is not a map for `%s'", lit.Value, fn)
+}
+
+// keyString resolves keys for comparison. Quoted and unquoted
+// strings are considered equal.
+func keyString(x ast.Expr) (string, error) {
+	lit, err := calc.Resolve(x, true)
+	if err != nil {
+		return "", err
+	}
+	return lit.Value, nil
+}
+
+// lookup finds the index of key in m, -1 if it is not present
+func lookup(m *ast.MapLit, key ast.Expr) (int, error) {
+	s, err := keyString(key)
+	if err != nil {
+		return -1, err
+	}
+	for i, kv := range m.Elts {
+		k, err := keyString(kv.Key)
+		if err != nil {
+			return -1, err
+		}
+		if k == s {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+func mapGet(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	m, err := toMap("map-get", args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := lookup(m, args[1])
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		return &ast.BasicLit{
+			Kind:     token.NULL,
+			ValuePos: call.Pos(),
+			Value:    "null",
+		}, nil
+	}
+	return m.Elts[i].Value, nil
+}
+
+func mapMerge(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	m1, err := toMap("map-merge", args[0])
+	if err != nil {
+		return nil, err
+	}
+	m2, err := toMap("map-merge", args[1])
+	if err != nil {
+		return nil, err
+	}
+	out := &ast.MapLit{
+		Lparen: call.Lparen,
+		Elts:   make([]*ast.KeyValueExpr, len(m1.Elts), len(m1.Elts)+len(m2.Elts)),
+		Rparen: call.Rparen,
+	}
+	copy(out.Elts, m1.Elts)
+	// Keys in $map2 replace those in $map1, but keep their position
+	for _, kv := range m2.Elts {
+		i, err := lookup(out, kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 {
+			out.Elts = append(out.Elts, kv)
+			continue
+		}
+		out.Elts[i] = kv
+	}
+	return out, nil
+}
+
+func mapRemove(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	m, err := toMap("map-remove", args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := lookup(m, args[1])
+	if err != nil {
+		return nil, err
+	}
+	out := &ast.MapLit{
+		Lparen: call.Lparen,
+		Elts:   make([]*ast.KeyValueExpr, 0, len(m.Elts)),
+		Rparen: call.Rparen,
+	}
+	out.Elts = append(out.Elts, m.Elts...)
+	if i >= 0 {
+		out.Elts = append(out.Elts[:i], out.Elts[i+1:]...)
+	}
+	return out, nil
+}
+
+func mapKeys(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	m, err := toMap("map-keys", args[0])
+	if err != nil {
+		return nil, err
+	}
+	list := &ast.ListLit{
+		ValuePos: call.Pos(),
+		EndPos:   call.End(),
+		Comma:    true,
+	}
+	for _, kv := range m.Elts {
+		list.Value = append(list.Value, kv.Key)
+	}
+	return list, nil
+}
+
+func mapValues(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	m, err := toMap("map-values", args[0])
+	if err != nil {
+		return nil, err
+	}
+	list := &ast.ListLit{
+		ValuePos: call.Pos(),
+		EndPos:   call.End(),
+		Comma:    true,
+	}
+	for _, kv := range m.Elts {
+		list.Value = append(list.Value, kv.Value)
+	}
+	return list, nil
+}
+
+func mapHasKey(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	m, err := toMap("map-has-key", args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := lookup(m, args[1])
+	if err != nil {
+		return nil, err
+	}
+	lit := &ast.BasicLit{
+		Kind:     token.STRING,
+		ValuePos: call.Pos(),
+		Value:    "false",
+	}
+	if i >= 0 {
+		lit.Value = "true"
+	}
+	return lit, nil
+}
+
+// keywords returns the keywords passed to a variable argument
+func keywords(call *ast.CallExpr, args ...ast.Expr) (ast.Expr, error) {
+	x := args[0]
+	for {
+		switch v := x.(type) {
+		case *ast.Ident:
+			if v.Obj == nil {
+				return nil, fmt.Errorf("Undefined variable: \"%s\".", v.Name)
+			}
+			assign, ok := v.Obj.Decl.(*ast.AssignStmt)
+			if !ok {
+				return nil, fmt.Errorf("$args: %s is not a variable argument list for `keywords'", v.Name)
+			}
+			x = assign.Rhs[0]
+		case *ast.CallExpr:
+			x = v.Resolved
+		case *ast.ListLit:
+			if v.Keywords != nil {
+				return v.Keywords, nil
+			}
+			return &ast.MapLit{Lparen: call.Pos(), Rparen: call.End()}, nil
+		default:
+			lit, err := calc.Resolve(x, true)
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("$args: %s is not a variable argument list for `keywords'", lit.Value)
+		}
+	}
+}
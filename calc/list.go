@@ -0,0 +1,77 @@
+package calc
+
+import (
+	"strings"
+
+	"github.com/wellington/sass/ast"
+	"github.com/wellington/sass/token"
+)
+
+// Delim returns the separator printed between the values of a list
+func Delim(l *ast.ListLit) string {
+	switch {
+	case l.Comma:
+		return ", "
+	case l.Slash:
+		return " / "
+	}
+	return " "
+}
+
+// Inspect resolves in to its Sass representation. Unlike Resolve,
+// nested lists keep their parens ie. (a b) (c d) and lists of one
+// value their trailing comma ie. (a,)
+func Inspect(in ast.Expr) (*ast.BasicLit, error) {
+	l, ok := in.(*ast.ListLit)
+	if !ok {
+		lit, err := resolve(in, true)
+		if err == nil && lit.Kind == token.NULL {
+			// null is printed by inspect
+			lit = &ast.BasicLit{ValuePos: lit.Pos(), Kind: token.STRING, Value: "null"}
+		}
+		return lit, err
+	}
+	ss := make([]string, len(l.Value))
+	for i, x := range l.Value {
+		lit, err := Inspect(x)
+		if err != nil {
+			return nil, err
+		}
+		ss[i] = lit.Value
+		if needsParens(l, x) {
+			ss[i] = "(" + ss[i] + ")"
+		}
+	}
+	s := strings.Join(ss, Delim(l))
+	single := len(l.Value) == 1 && l.Comma
+	if single {
+		s += ","
+	}
+	switch {
+	case l.Bracket:
+		s = "[" + s + "]"
+	case single, len(l.Value) == 0:
+		s = "(" + s + ")"
+	}
+	return &ast.BasicLit{
+		ValuePos: l.Pos(),
+		Kind:     token.STRING,
+		Value:    s,
+	}, nil
+}
+
+// needsParens reports whether x must be wrapped in parens to be read
+// back as a single value of list
+func needsParens(list *ast.ListLit, x ast.Expr) bool {
+	l, ok := x.(*ast.ListLit)
+	if !ok || l.Bracket || len(l.Value) < 2 {
+		return false
+	}
+	switch {
+	case list.Comma:
+		return l.Comma
+	case list.Slash:
+		return l.Comma || l.Slash
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package calc
+
+import (
+	"testing"
+
+	"github.com/wellington/sass/ast"
+	"github.com/wellington/sass/token"
+)
+
+func str(s string) *ast.BasicLit {
+	return &ast.BasicLit{Kind: token.STRING, Value: s}
+}
+
+func TestInspect(t *testing.T) {
+	space := &ast.ListLit{Value: []ast.Expr{str("a"), str("b")}}
+	comma := &ast.ListLit{Value: []ast.Expr{str("c"), str("d")}, Comma: true}
+	table := []struct {
+		in  ast.Expr
+		out string
+	}{
+		{&ast.ListLit{Value: []ast.Expr{space, str("e")}}, "(a b) e"},
+		{&ast.ListLit{Value: []ast.Expr{space, comma}, Comma: true}, "a b, (c, d)"},
+		{&ast.ListLit{Value: []ast.Expr{str("a")}, Comma: true}, "(a,)"},
+		{&ast.ListLit{Value: []ast.Expr{space}, Bracket: true}, "[(a b)]"},
+		{&ast.ListLit{Value: []ast.Expr{str("a"), str("b")}, Slash: true}, "a / b"},
+		{&ast.ListLit{}, "()"},
+		{str("a"), "a"},
+	}
+	for _, tt := range table {
+		lit, err := Inspect(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lit.Value != tt.out {
+			t.Errorf("got: %s wanted: %s", lit.Value, tt.out)
+		}
+	}
+}
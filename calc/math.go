@@ -3,9 +3,11 @@ package calc
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 
 	"github.com/wellington/sass/ast"
+	"github.com/wellington/sass/ast/unit"
 	"github.com/wellington/sass/token"
 )
 
@@ -33,10 +35,7 @@ func resolve(in ast.Expr, doOp bool) (*ast.BasicLit, error) {
 		x.Value = strings.Join(list, "")
 	case *ast.ListLit:
 		// During expr simplification, list are just string
-		delim := " "
-		if v.Comma {
-			delim = ", "
-		}
+		delim := Delim(v)
 		var k token.Token
 		ss := make([]string, len(v.Value))
 		for i := range v.Value {
@@ -52,11 +51,47 @@ func resolve(in ast.Expr, doOp bool) (*ast.BasicLit, error) {
 			Value:    strings.Join(ss, delim),
 			ValuePos: v.Pos(),
 		}
-		if len(v.Value) == 1 {
+		if v.Bracket {
+			x.Value = "[" + x.Value + "]"
+		} else if len(v.Value) == 1 {
 			x.Kind = k
 		}
+	case *ast.MapLit:
+		// Maps are not valid values, but they do have a string
+		// representation ie. inspect($map)
+		ss := make([]string, len(v.Elts))
+		for i, kv := range v.Elts {
+			key, err := resolve(kv.Key, doOp)
+			if err != nil {
+				return nil, err
+			}
+			val, err := resolve(kv.Value, doOp)
+			if err != nil {
+				return nil, err
+			}
+			ss[i] = key.Value + ": " + val.Value
+		}
+		x.Kind = token.STRING
+		x.Value = "(" + strings.Join(ss, ", ") + ")"
 	case *ast.UnaryExpr:
-		x = v.X.(*ast.BasicLit)
+		if v.Op != token.NOT && v.Op != token.SUB {
+			x = v.X.(*ast.BasicLit)
+			break
+		}
+		lit, err := resolve(v.X, true)
+		if err != nil {
+			return nil, err
+		}
+		if v.Op == token.SUB {
+			x.Kind = lit.Kind
+			x.Value = "-" + lit.Value
+			if strings.HasPrefix(lit.Value, "-") {
+				x.Value = lit.Value[1:]
+			}
+			break
+		}
+		x.Kind = token.STRING
+		x.Value = strconv.FormatBool(!Truthy(lit))
 	case *ast.BinaryExpr:
 		x, err = binary(v, doOp)
 	case *ast.BasicLit:
@@ -127,10 +162,13 @@ func binary(in *ast.BinaryExpr, doOp bool) (*ast.BasicLit, error) {
 		doOp = true
 	}
 
-	if _, ok := in.X.(*ast.Ident); ok {
+	// Variables and function results always perform math
+	switch in.X.(type) {
+	case *ast.Ident, *ast.CallExpr:
 		doOp = true
 	}
-	if _, ok := in.Y.(*ast.Ident); ok {
+	switch in.Y.(type) {
+	case *ast.Ident, *ast.CallExpr:
 		doOp = true
 	}
 
@@ -153,13 +191,26 @@ func binary(in *ast.BinaryExpr, doOp bool) (*ast.BasicLit, error) {
 	switch in.Op {
 	case token.ADD, token.SUB, token.MUL, token.QUO:
 		return combineLits(in.Op, left, right, doOp)
-	case token.EQL:
-		out.Value = "false"
-		if left.Value == right.Value {
-			out.Value = "true"
-		} else {
-			log.Printf("not equal % #v: % #v\n", left, right)
+	case token.LAND:
+		// and/or return one of their operands
+		if !Truthy(left) {
+			return left, nil
+		}
+		return right, nil
+	case token.LOR:
+		if Truthy(left) {
+			return left, nil
 		}
+		return right, nil
+	case token.EQL:
+		out.Kind = token.STRING
+		out.Value = strconv.FormatBool(equal(left, right))
+	case token.NEQ:
+		out.Kind = token.STRING
+		out.Value = strconv.FormatBool(!equal(left, right))
+	case token.LSS, token.GTR, token.LEQ, token.GEQ:
+		out.Kind = token.STRING
+		out.Value, err = compare(in.Op, left, right)
 	default:
 		fmt.Printf("l: % #v\nr: % #v\n", left, right)
 		err = fmt.Errorf("unsupported Operation %s", in.Op)
@@ -167,6 +218,87 @@ func binary(in *ast.BinaryExpr, doOp bool) (*ast.BasicLit, error) {
 	return out, err
 }
 
+// Truthy reports whether lit is true, only false and null are not
+func Truthy(lit *ast.BasicLit) bool {
+	if lit.Kind == token.NULL {
+		return false
+	}
+	return lit.Kind != token.STRING || lit.Value != "false"
+}
+
+// equal reports whether left and right are the same value. Numbers
+// are equal if their units convert to each other ie. 1in == 96px,
+// quotes are not significant for strings. A number never equals a
+// string.
+func equal(left, right *ast.BasicLit) bool {
+	if left.Kind == token.NULL || right.Kind == token.NULL {
+		return left.Kind == right.Kind
+	}
+	if isNumber(left.Kind) != isNumber(right.Kind) {
+		return false
+	}
+	if !isNumber(left.Kind) {
+		return left.Value == right.Value
+	}
+	// unitless numbers are not equal to numbers with a unit
+	if left.Kind.IsCSSNum() != right.Kind.IsCSSNum() {
+		return false
+	}
+	cmp, ok := cmpNumbers(left, right)
+	return ok && cmp == 0
+}
+
+// isNumber reports whether kind is a number with or without unit
+func isNumber(kind token.Token) bool {
+	return kind == token.INT || kind == token.FLOAT || kind.IsCSSNum()
+}
+
+// cmpNumbers compares the numbers left and right in the unit of left.
+// It reports false if the units can not be converted.
+func cmpNumbers(left, right *ast.BasicLit) (int, bool) {
+	l, err := unit.NewNum(left)
+	if err != nil {
+		return 0, false
+	}
+	r, err := unit.NewNum(right)
+	if err != nil {
+		return 0, false
+	}
+	// units unknown to the conversion ie. em only compare to
+	// themselves
+	if left.Kind != right.Kind && l.Unit == unit.INVALID &&
+		r.Unit == unit.INVALID {
+		return 0, false
+	}
+	return l.Cmp(r)
+}
+
+// compare evaluates relational operators, both sides must be numbers
+// with compatible units or no unit at all.
+func compare(op token.Token, left, right *ast.BasicLit) (string, error) {
+	if !isNumber(left.Kind) || !isNumber(right.Kind) {
+		return "", fmt.Errorf("Undefined operation: \"%s %s %s\".",
+			left.Value, op, right.Value)
+	}
+	cmp, ok := cmpNumbers(left, right)
+	if !ok {
+		return "", fmt.Errorf("Incompatible units: '%s' and '%s'.",
+			right.Kind, left.Kind)
+	}
+	var b bool
+	switch op {
+	case token.LSS:
+		b = cmp < 0
+	case token.GTR:
+		b = cmp > 0
+	case token.LEQ:
+		b = cmp <= 0
+	case token.GEQ:
+		b = cmp >= 0
+	}
+	return strconv.FormatBool(b), nil
+}
+
 func combineLits(op token.Token, left, right *ast.BasicLit, force bool) (*ast.BasicLit, error) {
 	return ast.Op(op, left, right, force)
 
@@ -1,6 +1,8 @@
 package calc
 
 import (
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/wellington/sass/ast"
@@ -66,3 +68,165 @@ func TestBinary_simple_str(t *testing.T) {
 	}
 
 }
+
+func TestBinary_compare(t *testing.T) {
+	for _, c := range []struct {
+		x  string
+		op token.Token
+		y  string
+		e  string
+	}{
+		{"1", token.LSS, "2", "true"},
+		{"2", token.LEQ, "2", "true"},
+		{"1.5", token.GTR, "2", "false"},
+		{"10px", token.GEQ, "2", "true"},
+		{"10px", token.LSS, "2px", "false"},
+		{"1in", token.GTR, "90px", "true"},
+		{"1em", token.GTR, "0", "true"},
+	} {
+		bin := &ast.BinaryExpr{
+			X:  numLit(c.x),
+			Op: c.op,
+			Y:  numLit(c.y),
+		}
+		lit, err := binary(bin, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lit.Value != c.e {
+			t.Errorf("%s %s %s got: %s wanted: %s",
+				c.x, c.op, c.y, lit.Value, c.e)
+		}
+	}
+
+	bin := &ast.BinaryExpr{
+		X:  numLit("1px"),
+		Op: token.LSS,
+		Y:  numLit("2em"),
+	}
+	_, err := binary(bin, true)
+	if e := "Incompatible units: 'em' and 'px'."; err == nil || err.Error() != e {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+}
+
+func TestBinary_equal(t *testing.T) {
+	for _, c := range []struct {
+		x  string
+		op token.Token
+		y  string
+		e  string
+	}{
+		{"1", token.EQL, "1.0", "true"},
+		{"1in", token.EQL, "96px", "true"},
+		{"2.54cm", token.EQL, "1in", "true"},
+		{"1turn", token.EQL, "360deg", "true"},
+		{"1px", token.EQL, "1", "false"},
+		{"1px", token.EQL, "1deg", "false"},
+		{"1px", token.NEQ, "2px", "true"},
+		{"a", token.EQL, "a", "true"},
+		{"a", token.NEQ, "b", "true"},
+		{"1", token.EQL, `"1"`, "false"},
+		{"1em", token.EQL, "1%", "false"},
+		{"2em", token.EQL, "2em", "true"},
+		{"10%", token.EQL, "10%", "true"},
+	} {
+		bin := &ast.BinaryExpr{
+			X:  numLit(c.x),
+			Op: c.op,
+			Y:  numLit(c.y),
+		}
+		lit, err := binary(bin, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lit.Value != c.e {
+			t.Errorf("%s %s %s got: %s wanted: %s",
+				c.x, c.op, c.y, lit.Value, c.e)
+		}
+	}
+}
+
+func TestBinary_bool(t *testing.T) {
+	for _, c := range []struct {
+		x  string
+		op token.Token
+		y  string
+		e  string
+	}{
+		{"true", token.LAND, "false", "false"},
+		{"1px", token.LAND, "2px", "2px"},
+		{"null", token.LAND, "a", "null"},
+		{"false", token.LOR, "a", "a"},
+		{"0", token.LOR, "a", "0"},
+	} {
+		bin := &ast.BinaryExpr{
+			X:  boolLit(c.x),
+			Op: c.op,
+			Y:  boolLit(c.y),
+		}
+		lit, err := binary(bin, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lit.Value != c.e {
+			t.Errorf("%s %s %s got: %s wanted: %s",
+				c.x, c.op, c.y, lit.Value, c.e)
+		}
+	}
+
+	lit, err := Resolve(&ast.UnaryExpr{
+		Op: token.NOT,
+		X:  boolLit("null"),
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := "true"; lit.Value != e {
+		t.Errorf("got: %s wanted: %s", lit.Value, e)
+	}
+
+	// a quoted "false" or "null" is a string, strings are true
+	for _, kind := range []token.Token{token.QSTRING, token.QSSTRING} {
+		for _, v := range []string{"false", "null"} {
+			if !Truthy(&ast.BasicLit{Kind: kind, Value: v}) {
+				t.Errorf("%s %q is not true", kind, v)
+			}
+		}
+	}
+	if equal(boolLit("null"), &ast.BasicLit{Kind: token.QSTRING, Value: "null"}) {
+		t.Error(`null == "null"`)
+	}
+}
+
+// boolLit returns the literal of the word v, null is the null value
+func boolLit(v string) *ast.BasicLit {
+	if v == "null" {
+		return &ast.BasicLit{Kind: token.NULL, Value: v}
+	}
+	return &ast.BasicLit{Kind: token.STRING, Value: v}
+}
+
+// numLit returns the literal of v with the kind the scanner gives it
+func numLit(v string) *ast.BasicLit {
+	if strings.HasPrefix(v, `"`) {
+		return &ast.BasicLit{Kind: token.QSTRING, Value: strings.Trim(v, `"`)}
+	}
+	if _, err := strconv.Atoi(v); err == nil {
+		return &ast.BasicLit{Kind: token.INT, Value: v}
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return &ast.BasicLit{Kind: token.FLOAT, Value: v}
+	}
+	if strings.HasSuffix(v, "%") {
+		return &ast.BasicLit{Kind: token.UPCT, Value: v}
+	}
+	for _, kind := range []token.Token{token.UIN, token.UCM, token.UMM,
+		token.UPC, token.UPX, token.UPT, token.DEG, token.GRAD,
+		token.RAD, token.TURN, token.UEM, token.UREM} {
+		if strings.HasSuffix(v, kind.String()) {
+			return &ast.BasicLit{Kind: kind, Value: v}
+		}
+	}
+	return &ast.BasicLit{Kind: token.STRING, Value: v}
+}
@@ -0,0 +1,161 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/wellington/sass/token"
+)
+
+func TestAtRoot(t *testing.T) {
+	in := `.a {
+  x: y;
+  @at-root .b { c: d; }
+  @at-root {
+    .e { f: g; }
+  }
+}
+`
+	e := `.a {
+  x: y; }
+
+.b {
+  c: d; }
+
+.e {
+  f: g; }
+`
+	runParse(t, in, e)
+}
+
+func TestAtRoot_bem(t *testing.T) {
+	in := `.block {
+  width: 1px;
+  @at-root #{&}__element { width: 2px; }
+  @at-root #{&}--modifier { width: 3px; }
+}
+`
+	e := `.block {
+  width: 1px; }
+
+.block__element {
+  width: 2px; }
+
+.block--modifier {
+  width: 3px; }
+`
+	runParse(t, in, e)
+}
+
+func TestSel_bem(t *testing.T) {
+	in := `.block {
+  &__element { width: 1px; }
+  &-suffix { width: 2px; }
+  &--modifier { width: 3px; }
+  &-a, &--b { width: 4px; }
+}
+`
+	e := `.block__element {
+  width: 1px; }
+
+.block-suffix {
+  width: 2px; }
+
+.block--modifier {
+  width: 3px; }
+
+.block-a, .block--b {
+  width: 4px; }
+`
+	runParse(t, in, e)
+}
+
+func TestAtRoot_for(t *testing.T) {
+	in := `@for $i from 1 through 2 {
+  .col-#{$i} {
+    @at-root #{&}-x { w: $i; }
+  }
+}
+`
+	e := `.col-1-x {
+  w: 1; }
+
+.col-2-x {
+  w: 2; }
+`
+	runParse(t, in, e)
+}
+
+func TestAtRoot_without(t *testing.T) {
+	in := `.a {
+  @media print {
+    x: y;
+    .b {
+      @at-root (without: media) { c: d; }
+      @at-root { .e { f: g; } }
+    }
+  }
+}
+`
+	e := `@media print {
+  .a {
+    x: y; }
+  .e {
+    f: g; } }
+
+.a .b {
+  c: d; }
+`
+	runParse(t, in, e)
+}
+
+func TestAtRoot_withoutTopMedia(t *testing.T) {
+	in := `@media screen {
+  .a {
+    @at-root (without: media) { x: y; }
+    b: c;
+  }
+}
+d { e: f; }
+`
+	e := `@media screen {
+  .a {
+    b: c; } }
+
+.a {
+  x: y; }
+
+d {
+  e: f; }
+`
+	runParse(t, in, e)
+}
+
+func TestAtRoot_with(t *testing.T) {
+	in := `.a {
+  @at-root (with: rule) { b: c; }
+  @at-root (without: all) {
+    .d { e: f; }
+  }
+}
+`
+	e := `.a {
+  b: c; }
+
+.d {
+  e: f; }
+`
+	runParse(t, in, e)
+
+	ctx := NewContext()
+	ctx.fset = token.NewFileSet()
+	_, err := ctx.runString("", `.a {
+  @at-root { b: c; }
+}
+`)
+	if err == nil {
+		t.Fatal("expected error for declaration outside of rule")
+	}
+	if e := "2:14: Declarations may only be used within style rules."; err.Error() != e {
+		t.Errorf("got: %s wanted: %s", err, e)
+	}
+}
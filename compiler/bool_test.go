@@ -0,0 +1,106 @@
+package compiler
+
+import "testing"
+
+func TestBool_operators(t *testing.T) {
+	in := `$t: true;
+$f: false;
+div {
+  a: $t and $f;
+  b: $f or $t;
+  c: not $f;
+  d: 1 == 1 and 2 == 2;
+  e: not (1 == 2);
+  f: false or 1px;
+  g: 1in == 96px;
+  h: 1px != 1deg;
+}
+`
+	e := `div {
+  a: false;
+  b: true;
+  c: true;
+  d: true;
+  e: true;
+  f: 1px;
+  g: true;
+  h: true; }
+`
+	runParse(t, in, e)
+}
+
+func TestBool_if(t *testing.T) {
+	in := `$x: 1;
+div {
+  @if 0 { a: zero; }
+  @if "" { b: empty; }
+  @if null { c: null; } @else { c: else; }
+  @if $x == 1 and not false { d: and; }
+  @if not $x { e: not; } @else if $x or null { e: or; }
+}
+`
+	e := `div {
+  a: zero;
+  b: empty;
+  c: else;
+  d: and;
+  e: or; }
+`
+	runParse(t, in, e)
+}
+
+func TestBool_null(t *testing.T) {
+	in := `$n: null;
+@mixin m($c: null) {
+  color: $c;
+  width: 1px;
+}
+div {
+  a: null;
+  b: $n;
+  c: ();
+  d: x null y;
+  e: if(false, 1, null);
+  f: type-of(null) == "null";
+  g: "null";
+  h: inspect(null) "a" "null" "b";
+  i: "null" == null;
+  j: not "false";
+  @include m;
+}
+p { a: null; }
+`
+	e := `div {
+  d: x y;
+  f: true;
+  g: "null";
+  h: null "a" "null" "b";
+  i: false;
+  j: false;
+  width: 1px; }
+`
+	runParse(t, in, e)
+}
+
+func TestBool_nullMixin(t *testing.T) {
+	in := `@mixin m($x) {
+  @if $x { a: yes; } @else { a: no; }
+}
+@mixin d($x: null) {
+  @if $x { b: yes; } @else { b: no; }
+}
+div {
+  @include m(null);
+  @include m(0);
+  @include m(false);
+  @include d();
+}
+`
+	e := `div {
+  a: no;
+  a: yes;
+  a: no;
+  b: no; }
+`
+	runParse(t, in, e)
+}
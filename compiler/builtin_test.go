@@ -52,6 +52,7 @@ func TestBuiltin_typeof(t *testing.T) {
 		d: type-of("a");
 		e: type-of('a');
         f: type-of($x);
+		g: type-of(true);
 	}`
 
 	e := `hey, ho {
@@ -60,7 +61,8 @@ func TestBuiltin_typeof(t *testing.T) {
   c: color;
   d: string;
   e: string;
-  f: number; }
+  f: number;
+  g: bool; }
 `
 	runParse(t, in, e)
 
@@ -88,3 +90,34 @@ func TestBuiltin_nth(t *testing.T) {
 `
 	runParse(t, in, e)
 }
+
+func TestBuiltin_if(t *testing.T) {
+	in := `$x: 2px;
+$m: (a: 1px);
+@function abs($n) {
+  @return if($n < 0, -$n, $n);
+}
+@mixin size($w: null) {
+  width: if($w, $w, auto);
+}
+div {
+  a: if(true, $x, $undefined);
+  b: if($x == 1px, one, two);
+  c: if(false, map-get($undefined, a), map-get($m, a));
+  d: abs(-3);
+  e: if($condition: null, $if-true: a, $if-false: b c);
+  @include size;
+  @include size(3px);
+}
+`
+	e := `div {
+  a: 2px;
+  b: two;
+  c: 1px;
+  d: 3;
+  e: b c;
+  width: auto;
+  width: 3px; }
+`
+	runParse(t, in, e)
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/wellington/sass/ast"
 	"github.com/wellington/sass/calc"
 	"github.com/wellington/sass/parser"
+	"github.com/wellington/sass/strops"
 	"github.com/wellington/sass/token"
 )
 
@@ -19,6 +21,16 @@ type Context struct {
 	buf      *bytes.Buffer
 	fileName *ast.Ident
 	mode     parser.Mode
+	// maxIterations limits @while loops, zero uses the parser default
+	maxIterations int
+	// diagnostics receives messages of @debug and @warn
+	diagnostics func(parser.Diagnostic)
+	// importer finds imports, nil imports relative to the file
+	importer parser.Importer
+	// roots confine the files read by a parser.FileImporter
+	roots []string
+	// files and glob patterns imported by the last compile
+	deps, globs []string
 
 	err error
 	// Records the current level of selectors
@@ -30,18 +42,23 @@ type Context struct {
 	// it is never flushed, but will be replaced when the next
 	// selstmt is encountered
 	activeSel *ast.BasicLit
-	// activeMedia maintains the current media query
-	// Once flushed, it should never be printed again
-	activeMedia *ast.BasicLit
-	// indicates that a media closing bracket needs to be
-	// flushed
-	inMedia     bool
+	// indicates that rules are printed inside of @media
+	inMedia bool
+	// @media found inside of @media, they are printed after it
+	nestedMedia []*ast.MediaStmt
 	firstRule   bool // first rules print { otherwise don't
 	hiddenBlock bool // @each has hidden blocks, probably other examples of this
+	placeholder bool // rules of placeholder selectors are not printed
+	bare        bool // rules of directives without a selector ie. @font-face
 	level       int
 	printers    map[ast.Node]func(*Context, ast.Node)
 	fset        *token.FileSet
 	scope       Scope
+
+	// extends found in the stylesheet and the selectors
+	// rewritten by them
+	extends  []*extension
+	extended map[*ast.SelStmt]*ast.BasicLit
 }
 
 // NewContext returns a new, initialized context
@@ -68,6 +85,12 @@ func Run(path string) (string, error) {
 	return string(out), err
 }
 
+// Run compiles the file at path with the options of ctx, files are
+// read by its importer
+func (ctx *Context) Run(path string) (string, error) {
+	return ctx.runString(path, nil)
+}
+
 // SetMode modifies the mode that the parser runs in. See parser.Mode for
 // available options
 func (ctx *Context) SetMode(mode parser.Mode) error {
@@ -75,6 +98,63 @@ func (ctx *Context) SetMode(mode parser.Mode) error {
 	return nil
 }
 
+// SetMaxIterations limits the number of times a @while loop may run
+// before compilation fails. See parser.DefaultMaxIterations for the
+// default.
+func (ctx *Context) SetMaxIterations(n int) {
+	ctx.maxIterations = n
+}
+
+// SetDiagnostics sets the handler of @debug and @warn messages. By
+// default, they are printed to stderr. A nil handler drops them.
+func (ctx *Context) SetDiagnostics(fn func(parser.Diagnostic)) {
+	ctx.diagnostics = fn
+}
+
+// SetImporter sets the importer of @import, @use and @forward. Use
+// parser.NewFileImporter to search include paths, parser.NewFSImporter
+// or parser.NewMapImporter to compile without a file system.
+func (ctx *Context) SetImporter(imp parser.Importer) {
+	ctx.importer = imp
+}
+
+// SetAllowedRoots confines imports to files inside of the directories
+// roots, other imports are reported as errors. Roots apply to the
+// default importer and to parser.FileImporter, compiling with any
+// other importer and roots is an error.
+func (ctx *Context) SetAllowedRoots(roots ...string) {
+	ctx.roots = roots
+}
+
+// fileImporter returns the importer of ctx confined to its roots
+func (ctx *Context) fileImporter() (parser.Importer, error) {
+	if len(ctx.roots) == 0 {
+		return ctx.importer, nil
+	}
+	imp := parser.NewFileImporter()
+	switch v := ctx.importer.(type) {
+	case nil:
+	case *parser.FileImporter:
+		*imp = *v
+	default:
+		return nil, fmt.Errorf("allowed roots are not supported by %T", v)
+	}
+	imp.Roots = ctx.roots
+	return imp, nil
+}
+
+// Dependencies returns the files imported by the last compile and the
+// patterns of its glob imports. Watchers compile again when the files
+// change or new files match the patterns.
+func (ctx *Context) Dependencies() (files []string, globs []string) {
+	return ctx.deps, ctx.globs
+}
+
+// stderrDiagnostics prints messages on their own line to stderr
+func stderrDiagnostics(d parser.Diagnostic) {
+	fmt.Fprintln(os.Stderr, d)
+}
+
 func (ctx *Context) runString(path string, src interface{}) (string, error) {
 	b, err := ctx.run(path, src)
 	return string(b), err
@@ -83,20 +163,49 @@ func (ctx *Context) runString(path string, src interface{}) (string, error) {
 func (ctx *Context) run(path string, src interface{}) ([]byte, error) {
 
 	ctx.fset = token.NewFileSet()
+	imp, err := ctx.fileImporter()
+	if err != nil {
+		return nil, err
+	}
 	// ctx.mode = parser.Trace
-	pf, err := parser.ParseFile(ctx.fset, path, src, ctx.mode)
+	pf, err := parser.ParseFileOptions(ctx.fset, path, src, ctx.mode,
+		&parser.Options{
+			MaxIterations: ctx.maxIterations,
+			Importer:      imp,
+		})
 	if err != nil {
 		return nil, err
 	}
+	ctx.deps, ctx.globs = pf.Deps, pf.Globs
+
+	if err := ctx.extend(pf); err != nil {
+		return nil, err
+	}
 
+	imports := plainImports(pf)
 	ast.Walk(ctx, pf)
+	if ctx.err != nil {
+		return nil, ctx.err
+	}
 	lr, _ := utf8.DecodeLastRune(ctx.buf.Bytes())
 	_ = lr
 	if ctx.buf.Len() > 0 && lr != '\n' {
 		ctx.out("\n")
 	}
 	// ctx.printSels(pf.Decls)
-	return ctx.buf.Bytes(), nil
+	out := ctx.buf.Bytes()
+	if len(imports) == 0 {
+		return out, nil
+	}
+	// @charset must stay the first rule, ahead of the hoisted imports
+	var charset []byte
+	if bytes.HasPrefix(out, []byte("@charset")) {
+		i := bytes.IndexByte(out, '\n') + 1
+		charset, out = out[:i], bytes.TrimLeft(out[i:], "\n")
+	}
+	res := make([]byte, 0, len(charset)+len(imports)+len(out))
+	res = append(append(append(res, charset...), imports...), out...)
+	return res, nil
 }
 
 // out prints with the appropriate indention, selectors always have indent
@@ -124,6 +233,9 @@ func (ctx *Context) blockIntro() {
 	}
 
 	ctx.firstRule = false
+	if ctx.bare {
+		return
+	}
 
 	// Only print newlines if there is text in the buffer
 	if ctx.buf.Len() > 0 {
@@ -132,15 +244,6 @@ func (ctx *Context) blockIntro() {
 		}
 	}
 
-	if ctx.activeMedia != nil {
-		val := ctx.activeMedia.Value
-		ctx.activeMedia = nil
-		// media queries have invalid indention, move up one
-		ctx.level--
-		ctx.out(fmt.Sprintf("%s {\n", val))
-		ctx.level++
-	}
-
 	sel := "MISSING"
 	if ctx.activeSel != nil {
 		sel = ctx.activeSel.Value
@@ -161,10 +264,6 @@ func (ctx *Context) blockOutro() {
 
 	ctx.firstRule = true
 	buf := " }\n"
-	if ctx.inMedia {
-		ctx.inMedia = false
-		buf = " }" + buf
-	}
 	// if !skipParen {
 	fmt.Fprintf(ctx.buf, buf)
 	// }
@@ -174,35 +273,36 @@ func (ctx *Context) blockOutro() {
 // to walk through the parser AST tree.
 func (ctx *Context) Visit(node ast.Node) ast.Visitor {
 	if ctx.err != nil {
-		fmt.Println(ctx.err)
 		return nil
 	}
 	var key ast.Node
 	switch v := node.(type) {
 	case *ast.BlockStmt:
-		if (ctx.scope.RuleLen() > 0 || ctx.activeMedia != nil) &&
-			!ctx.hiddenBlock {
+		// hidden only applies to this block, not the blocks
+		// nested inside of it
+		hidden := ctx.hiddenBlock
+		ctx.hiddenBlock = false
+		if ctx.scope.RuleLen() > 0 && !hidden {
 			ctx.level = ctx.level + 1
 			if !ctx.firstRule {
 				fmt.Fprintf(ctx.buf, " }\n")
 			}
 		}
 		ctx.scope = NewScope(ctx.scope)
-		if !ctx.hiddenBlock {
+		if !hidden {
 			ctx.firstRule = true
 		}
 		for _, node := range v.List {
 			ast.Walk(ctx, node)
 		}
-		if ctx.level > 0 {
+		if ctx.level > 0 && !hidden {
 			ctx.level = ctx.level - 1
 		}
 		ctx.scope = CloseScope(ctx.scope)
-		if !ctx.hiddenBlock {
+		if !hidden {
 			ctx.blockOutro()
 			ctx.firstRule = true
 		}
-		ctx.hiddenBlock = false
 		// ast.Walk(ctx, v.List)
 		// fmt.Fprintf(ctx.buf, "}")
 		return nil
@@ -241,16 +341,44 @@ func (ctx *Context) Visit(node ast.Node) ast.Visitor {
 	case *ast.CallExpr:
 	case nil:
 		return ctx
+	case *ast.MediaDecl:
 	case *ast.MediaStmt:
-		fmt.Println("mediastmt")
-		key = mediaStmt
+		ctx.printers[mediaStmt](ctx, node)
+		// The body was printed by @media
+		return nil
 	case *ast.EmptyStmt:
+	case *ast.ExtendStmt:
+		// Extends were applied before printing
+	case *ast.DebugDecl:
+	case *ast.DebugStmt:
+		key = debugStmt
+	case *ast.DirectiveDecl:
+	case *ast.DirectiveStmt:
+		ctx.printers[directive](ctx, node)
+		// The body was printed by the directive
+		return nil
+	case *ast.AtRootDecl:
+	case *ast.AtRootStmt:
+		ctx.printers[atRootStmt](ctx, node)
+		// The body was printed at the root
+		return nil
 	case *ast.AssignStmt:
 		key = assignStmt
+	case *ast.EachDecl:
 	case *ast.EachStmt:
 		key = eachStmt
+	case *ast.ForDecl:
+	case *ast.ForStmt:
+		key = forStmt
+	case *ast.WhileDecl:
+	case *ast.WhileStmt:
+		key = whileStmt
 	case *ast.ListLit:
+	case *ast.MapLit, *ast.KeyValueExpr:
 	case *ast.ImportSpec:
+	case *ast.UseSpec, *ast.ForwardSpec:
+		// modules were loaded by the parser
+		return nil
 	case *ast.IfDecl:
 	case *ast.IfStmt:
 		key = ifStmt
@@ -278,11 +406,18 @@ var (
 	includeSpec *ast.IncludeSpec
 	mediaStmt   *ast.MediaStmt
 	eachStmt    *ast.EachStmt
+	forStmt     *ast.ForStmt
+	whileStmt   *ast.WhileStmt
+	atRootStmt  *ast.AtRootStmt
+	debugStmt   *ast.DebugStmt
+	directive   *ast.DirectiveStmt
 	ifStmt      *ast.IfStmt
 )
 
 func (ctx *Context) init() {
 	ctx.buf = bytes.NewBuffer(nil)
+	// no block is open
+	ctx.firstRule = true
 	ctx.printers = make(map[ast.Node]func(*Context, ast.Node))
 	ctx.printers[valueSpec] = visitValueSpec
 	ctx.printers[funcDecl] = visitFunc
@@ -298,12 +433,21 @@ func (ctx *Context) init() {
 	ctx.printers[comment] = printComment
 	ctx.printers[mediaStmt] = printMedia
 	ctx.printers[eachStmt] = printEach
+	ctx.printers[forStmt] = printFor
+	ctx.printers[whileStmt] = printFor
+	ctx.printers[atRootStmt] = printAtRoot
+	ctx.printers[debugStmt] = printDebug
+	ctx.printers[directive] = printDirective
+	ctx.diagnostics = stderrDiagnostics
 	ctx.scope = NewScope(empty)
 	// ctx.printers[typeSpec] = visitTypeSpec
 	// assign printers
 }
 
 func printComment(ctx *Context, n ast.Node) {
+	if ctx.placeholder {
+		return
+	}
 	ctx.blockIntro()
 	cmt := n.(*ast.Comment)
 	// These additional spaces should be handled by out()
@@ -333,33 +477,290 @@ func printExpr(ctx *Context, n ast.Node) {
 func printSelStmt(ctx *Context, n ast.Node) {
 	stmt := n.(*ast.SelStmt)
 	ctx.activeSel = stmt.Resolved
+	if sel, ok := ctx.extended[stmt]; ok {
+		ctx.activeSel = sel
+	}
+	ctx.activeSel = dropPlaceholders(ctx.activeSel)
+	ctx.placeholder = ctx.activeSel == nil
 }
 
 func printRuleSpec(ctx *Context, n ast.Node) {
 	// Inspect the sel buffer and dump it
 	// Also need to track what level was last dumped
 	// so selectors don't get printed twice
-	ctx.blockIntro()
+	if ctx.placeholder {
+		return
+	}
 
 	spec := n.(*ast.RuleSpec)
+	s, err := simplifyExprs(ctx, spec.Values)
+	if err != nil {
+		ctx.err = err
+		return
+	}
+	// null and empty lists are not printed
+	if len(s) == 0 {
+		return
+	}
+	ctx.blockIntro()
 	ctx.scope.RuleAdd(spec)
 	ctx.out(fmt.Sprintf("  %s: ", spec.Name))
-	var s string
-	s, ctx.err = simplifyExprs(ctx, spec.Values)
 	fmt.Fprintf(ctx.buf, "%s;", s)
 }
 
 func printEach(ctx *Context, n ast.Node) {
 	// surprise, not media but behavior is same!
 	ctx.hiddenBlock = true
-	fmt.Println("each...")
-	ast.Print(token.NewFileSet(), n)
 }
 
+func printFor(ctx *Context, n ast.Node) {
+	// The body was unrolled by the parser, print it like @each
+	ctx.hiddenBlock = true
+}
+
+func printAtRoot(ctx *Context, n ast.Node) {
+	stmt := n.(*ast.AtRootStmt)
+	// close the open block, the body is printed without indention
+	if !ctx.firstRule {
+		fmt.Fprint(ctx.buf, " }\n")
+		ctx.firstRule = true
+	}
+	level := ctx.level
+	ctx.level = 0
+	if ctx.inMedia {
+		ctx.level = 1
+	}
+	ctx.scope = NewScope(ctx.scope)
+	// Selectors in the body were resolved without their parents by
+	// the parser. Rules need the selector @at-root was found in.
+	if stmt.Sel != nil {
+		ctx.activeSel = stmt.Sel
+		ctx.placeholder = false
+	} else {
+		ctx.hiddenBlock = true
+	}
+	ast.Walk(ctx, stmt.Body)
+	ctx.scope = CloseScope(ctx.scope)
+	ctx.level = level
+}
+
+// printDirective prints CSS at-rules as they were found. Directives
+// nested in a selector bubble up, the selector wraps the rules of the
+// body ie. @supports (x) { .a { b: c; } }
+func printDirective(ctx *Context, n ast.Node) {
+	stmt := n.(*ast.DirectiveStmt)
+	header := stmt.Name
+	if stmt.Prelude != nil {
+		header = strings.TrimSpace(header + " " + stmt.Prelude.Value)
+	}
+	if stmt.Body == nil && stmt.Sel != nil {
+		// Printed with the rules of the selector
+		if ctx.placeholder {
+			return
+		}
+		ctx.blockIntro()
+		ctx.out(fmt.Sprintf("  %s;", header))
+		return
+	}
+
+	ctx.printAtRule(header, stmt.Sel, stmt.Body)
+}
+
+// printAtRule prints header and the statements of body. Rules in body
+// are wrapped in sel, without one they belong to the at-rule itself
+// ie. @font-face { font-family: x; }
+func (ctx *Context) printAtRule(header string, sel *ast.BasicLit, body *ast.BlockStmt) {
+	// close the open block, at-rules are printed after it
+	level := ctx.level
+	if !ctx.firstRule {
+		fmt.Fprint(ctx.buf, " }\n")
+		ctx.firstRule = true
+	}
+	if ctx.scope.RuleLen() > 0 {
+		ctx.level++
+	}
+	mark := ctx.buf.Len()
+	if ctx.level == 0 && ctx.buf.Len() > 0 {
+		fmt.Fprint(ctx.buf, "\n")
+	}
+	if body == nil {
+		ctx.out(header + ";\n")
+		ctx.level = level
+		return
+	}
+	ctx.out(header + " {\n")
+	head := ctx.buf.Len()
+
+	lvl := ctx.level
+	active, placeholder, bare := ctx.activeSel, ctx.placeholder, ctx.bare
+	ctx.activeSel = nil
+	ctx.placeholder = false
+	if sel != nil {
+		ctx.activeSel = dropPlaceholders(ctx.extendedSel(sel))
+		ctx.placeholder = ctx.activeSel == nil
+	}
+	ctx.scope = NewScope(ctx.scope)
+	for _, s := range body.List {
+		// rules without a selector stay at the level of the
+		// at-rule, selectors and at-rules are indented
+		ctx.level = lvl + 1
+		ctx.bare = sel == nil
+		switch s.(type) {
+		case *ast.SelStmt, *ast.DirectiveStmt, *ast.MediaStmt:
+			if ctx.bare && ctx.scope.RuleLen() > 0 {
+				// indented by the rules before them
+				ctx.level = lvl
+				if !ctx.firstRule {
+					fmt.Fprint(ctx.buf, "\n")
+					ctx.firstRule = true
+				}
+			}
+			ctx.bare = false
+		default:
+			if ctx.bare {
+				ctx.level = lvl
+			}
+		}
+		ast.Walk(ctx, s)
+	}
+	switch {
+	case ctx.buf.Len() == head:
+		// nothing was printed, drop the at-rule
+		ctx.buf.Truncate(mark)
+	case ctx.firstRule:
+		// the last block was closed, add the closing of the at-rule
+		ctx.buf.Truncate(ctx.buf.Len() - 1)
+		fmt.Fprint(ctx.buf, " }\n")
+	case sel == nil:
+		fmt.Fprint(ctx.buf, " }\n")
+	default:
+		fmt.Fprint(ctx.buf, " } }\n")
+	}
+	ctx.scope = CloseScope(ctx.scope)
+	ctx.activeSel, ctx.placeholder, ctx.bare = active, placeholder, bare
+	ctx.firstRule = true
+	ctx.level = level
+}
+
+// printDebug sends @debug and @warn to the diagnostics handler, @error
+// stops compilation.
+func printDebug(ctx *Context, n ast.Node) {
+	stmt := n.(*ast.DebugStmt)
+	list := []ast.Expr{stmt.X}
+	if s, ok := stmt.X.(*ast.StringExpr); ok {
+		list = s.List
+	}
+	ss := make([]string, 0, len(list))
+	for _, x := range list {
+		// messages are printed without quotes
+		if lit, ok := x.(*ast.BasicLit); ok && lit.Kind == token.QSTRING {
+			ss = append(ss, strops.Unquote(lit.Value))
+			continue
+		}
+		var s string
+		var err error
+		if stmt.Tok == token.DEBUG {
+			s, err = inspectExpr(ctx, x)
+		} else {
+			s, err = resolveExpr(ctx, x, false)
+		}
+		if err != nil {
+			ctx.err = err
+			return
+		}
+		ss = append(ss, strops.Unquote(s))
+	}
+	msg := strings.Join(ss, " ")
+	if stmt.Tok == token.ERROR {
+		ctx.err = ctx.errorf(stmt.Pos(), "%s", msg)
+		return
+	}
+	if ctx.diagnostics != nil {
+		ctx.diagnostics(parser.Diagnostic{
+			Tok: stmt.Tok,
+			Pos: ctx.fset.Position(stmt.Pos()),
+			Msg: msg,
+		})
+	}
+}
+
+// inspectExpr prints x as @debug shows it. Maps, null and nested
+// lists are printed as Sass values rather than as CSS.
+func inspectExpr(ctx *Context, x ast.Expr) (string, error) {
+	for {
+		ident, ok := x.(*ast.Ident)
+		if !ok || ident.Obj == nil {
+			break
+		}
+		switch decl := ident.Obj.Decl.(type) {
+		case *ast.AssignStmt:
+			if len(decl.Rhs) != 1 {
+				return resolveExpr(ctx, x, false)
+			}
+			x = decl.Rhs[0]
+			continue
+		case *ast.MapLit, *ast.ListLit, *ast.BasicLit:
+			x = decl.(ast.Expr)
+		}
+		break
+	}
+	switch x.(type) {
+	case *ast.MapLit, *ast.ListLit, *ast.BasicLit:
+		lit, err := calc.Inspect(x)
+		if err != nil {
+			return "", err
+		}
+		return lit.Value, nil
+	}
+	return resolveExpr(ctx, x, false)
+}
+
+// printMedia prints @media with the queries merged by the parser,
+// queries that never match are not printed
 func printMedia(ctx *Context, n ast.Node) {
 	stmt := n.(*ast.MediaStmt)
-	ctx.activeMedia = stmt.Query
+	if len(stmt.Queries) == 0 {
+		return
+	}
+	if ctx.inMedia {
+		ctx.nestedMedia = append(ctx.nestedMedia, stmt)
+		return
+	}
 	ctx.inMedia = true
+	ctx.printAtRule(stmt.Name.Name+" "+stmt.Query.Value, stmt.Sel, stmt.Body)
+	ctx.inMedia = false
+	nested := ctx.nestedMedia
+	ctx.nestedMedia = nil
+	for _, media := range nested {
+		printMedia(ctx, media)
+	}
+}
+
+// plainImports prints the plain CSS imports of f, they are hoisted to
+// the top of the output. Sass imports were inlined by the parser.
+func plainImports(f *ast.File) []byte {
+	var buf bytes.Buffer
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.FuncDecl:
+			return false
+		case *ast.ImportSpec:
+			if !v.Plain {
+				return false
+			}
+			path := v.Path.Value
+			if v.Path.Kind != token.STRING {
+				path = `"` + path + `"`
+			}
+			if v.Media != nil {
+				path += " " + v.Media.Value
+			}
+			fmt.Fprintf(&buf, "@import %s;\n", path)
+			return false
+		}
+		return true
+	})
+	return buf.Bytes()
 }
 
 func printPropValueSpec(ctx *Context, n ast.Node) {
@@ -369,17 +770,51 @@ func printPropValueSpec(ctx *Context, n ast.Node) {
 
 func printIfStmt(ctx *Context, n ast.Node) {
 	ifStmt := n.(*ast.IfStmt)
-	s, err := resolveExpr(ctx, ifStmt.Cond, true)
+	ok, err := ctx.truthy(ifStmt.Cond)
 	if err != nil {
 		log.Fatal("failed to resolve @if", err)
 	}
-	if s == "true" {
+	if ok {
+		// like @each, the block belongs to the enclosing rule
+		ctx.hiddenBlock = true
 		ctx.Visit(ifStmt.Body)
-	} else {
+	} else if ifStmt.Else != nil {
+		ctx.hiddenBlock = true
 		ctx.Visit(ifStmt.Else)
 	}
 }
 
+// truthy evaluates the condition of @if, only false and null are
+// falsy
+func (ctx *Context) truthy(cond ast.Expr) (bool, error) {
+	s, err := resolveExpr(ctx, cond, true)
+	if err != nil {
+		return false, err
+	}
+	return s != "false" && !isNull(cond), nil
+}
+
+// isNull reports whether x is null or a variable set to null
+func isNull(x ast.Expr) bool {
+	for {
+		switch v := x.(type) {
+		case *ast.BasicLit:
+			return v.Kind == token.NULL
+		case *ast.Ident:
+			if v.Obj == nil {
+				return false
+			}
+			assign, ok := v.Obj.Decl.(*ast.AssignStmt)
+			if !ok || len(assign.Rhs) != 1 {
+				return false
+			}
+			x = assign.Rhs[0]
+		default:
+			return false
+		}
+	}
+}
+
 // Variable assignments inside blocks ie. mixins
 func visitAssignStmt(ctx *Context, n ast.Node) {
 	fmt.Println("visit Assign")
@@ -419,7 +854,12 @@ func calculateExprs(ctx *Context, bin *ast.BinaryExpr, doOp bool) (string, error
 
 func resolveIdent(ctx *Context, ident *ast.Ident) (out string) {
 	v := ident
-	if ident.Obj == nil {
+	if ident.Obj == nil || ident.Obj.Decl == nil {
+		// variables not found by the parser are not declared
+		if strings.HasPrefix(ident.Name, "$") && ctx.err == nil {
+			ctx.err = ctx.errorf(ident.Pos(),
+				"Undefined variable: \"%s\".", ident.Name)
+		}
 		out = ident.Name
 		return
 	}
@@ -464,9 +904,12 @@ func resolveIdent(ctx *Context, ident *ast.Ident) (out string) {
 
 // joinLits acts like strings.Join
 func joinLits(a []*ast.BasicLit, sep string) string {
-	s := make([]string, len(a))
-	for i := range a {
-		s[i] = a[i].Value
+	s := make([]string, 0, len(a))
+	for _, lit := range a {
+		// null is not printed
+		if lit.Kind != token.NULL {
+			s = append(s, lit.Value)
+		}
 	}
 	return strings.Join(s, sep)
 }
@@ -480,9 +923,23 @@ func resolveAssign(ctx *Context, astmt *ast.AssignStmt) (lits []*ast.BasicLit) {
 			// Replace Ident with underlying BasicLit
 			lits = append(lits, resolveAssign(ctx, assign)...)
 		case *ast.CallExpr:
-			lits = append(lits, v.Fun.(*ast.Ident).Obj.Decl.(*ast.BasicLit))
+			if lit, ok := v.Fun.(*ast.Ident).Obj.Decl.(*ast.BasicLit); ok {
+				lits = append(lits, lit)
+				break
+			}
+			// Functions may return lists or maps
+			out, err := resolveExpr(ctx, v, false)
+			if err != nil {
+				ctx.err = err
+			}
+			lits = append(lits, &ast.BasicLit{
+				Value: out,
+			})
 		case *ast.BasicLit:
 			lits = append(lits, v)
+		case *ast.UnaryExpr:
+			// & was replaced by the parent selector
+			lits = append(lits, v.X.(*ast.BasicLit))
 		case *ast.StringExpr:
 			list := make([]*ast.BasicLit, len(v.List))
 			for i := range v.List {
@@ -493,13 +950,23 @@ func resolveAssign(ctx *Context, astmt *ast.AssignStmt) (lits []*ast.BasicLit) {
 			list[last].Value = list[last].Value + `"`
 			lits = append(lits, list...)
 		case *ast.ListLit:
-			out, err := simplifyExprs(ctx, v.Value)
+			out, err := resolveExpr(ctx, v, false)
 			if err != nil {
 				log.Fatal(err)
 			}
 			lits = append(lits, &ast.BasicLit{
 				Value: out,
 			})
+		case *ast.BinaryExpr:
+			// stored values are calculated ie. 1px / 2px is 0.5,
+			// a / b stays slash separated
+			out, err := resolveExpr(ctx, v, true)
+			if err != nil {
+				ctx.err = err
+			}
+			lits = append(lits, &ast.BasicLit{
+				Value: out,
+			})
 		default:
 			log.Fatalf("default rhs %s % #v\n", rhs, rhs)
 		}
@@ -515,6 +982,12 @@ func resolveExpr(ctx *Context, expr ast.Expr, doOp bool) (out string, err error)
 		panic("ast.Value")
 	case *ast.BinaryExpr:
 		out, err = calculateExprs(ctx, v, doOp)
+	case *ast.UnaryExpr:
+		lit, err := calc.Resolve(v, doOp)
+		if err != nil {
+			return "", err
+		}
+		out = lit.Value
 	case *ast.CallExpr:
 		fn, ok := v.Fun.(*ast.Ident)
 		if !ok {
@@ -527,7 +1000,16 @@ func resolveExpr(ctx *Context, expr ast.Expr, doOp bool) (out string, err error)
 	case *ast.ParenExpr:
 		out, ctx.err = simplifyExprs(ctx, []ast.Expr{v.X})
 	case *ast.Ident:
+		if m, ok := mapLit(v); ok {
+			return resolveExpr(ctx, m, doOp)
+		}
 		out = resolveIdent(ctx, v)
+	case *ast.MapLit:
+		lit, err := calc.Resolve(v, doOp)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%s isn't a valid CSS value.", lit.Value)
 	case *ast.BasicLit:
 		switch v.Kind {
 		case token.VAR:
@@ -537,27 +1019,54 @@ func resolveExpr(ctx *Context, expr ast.Expr, doOp bool) (out string, err error)
 			// }
 		case token.QSTRING:
 			out = `"` + v.Value + `"`
+		case token.NULL:
+			// null is not printed
 		default:
 			out = v.Value
 		}
 	case *ast.ListLit:
-		vals := make([]string, len(v.Value))
-		delim := " "
-		if v.Comma {
-			delim = ", "
-		}
-		for i, x := range v.Value {
+		vals := make([]string, 0, len(v.Value))
+		delim := calc.Delim(v)
+		for _, x := range v.Value {
 			o, err := resolveExpr(ctx, x, v.Paren)
 			_ = err // fuq this error
-			vals[i] = o
+			// null and empty lists are left out of lists
+			if len(o) > 0 {
+				vals = append(vals, o)
+			}
+		}
+		out = strings.Join(vals, delim)
+		if v.Bracket {
+			out = "[" + out + "]"
 		}
-		return strings.Join(vals, delim), nil
+		return out, nil
 	default:
 		panic(fmt.Sprintf("unhandled expr: % #v\n", v))
 	}
 	return
 }
 
+// mapLit follows variables to the map they were assigned, if any
+func mapLit(expr ast.Expr) (*ast.MapLit, bool) {
+	for {
+		switch v := expr.(type) {
+		case *ast.MapLit:
+			return v, true
+		case *ast.Ident:
+			if v.Obj == nil {
+				return nil, false
+			}
+			assign, ok := v.Obj.Decl.(*ast.AssignStmt)
+			if !ok || len(assign.Rhs) != 1 {
+				return nil, false
+			}
+			expr = assign.Rhs[0]
+		default:
+			return nil, false
+		}
+	}
+}
+
 func simplifyExprs(ctx *Context, exprs []ast.Expr) (string, error) {
 	sums := make([]string, 0, len(exprs))
 	for _, expr := range exprs {
@@ -565,7 +1074,9 @@ func simplifyExprs(ctx *Context, exprs []ast.Expr) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		sums = append(sums, s)
+		if len(s) > 0 {
+			sums = append(sums, s)
+		}
 	}
 
 	return strings.Join(sums, " "), nil
@@ -0,0 +1,176 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wellington/sass/parser"
+	"github.com/wellington/sass/token"
+)
+
+func runDiagnostics(in string) ([]string, error) {
+	ctx := NewContext()
+	ctx.fset = token.NewFileSet()
+	var msgs []string
+	ctx.SetDiagnostics(func(d parser.Diagnostic) {
+		msgs = append(msgs, d.String())
+	})
+	_, err := ctx.runString("", in)
+	return msgs, err
+}
+
+func TestDebug(t *testing.T) {
+	in := `$x: 3px;
+@debug $x * 2;
+@warn "x is #{$x}";
+@function double($n) {
+  @debug "double";
+  @return $n * 2;
+}
+@mixin m($a) {
+  @warn "m" $a;
+  b: $a;
+}
+a {
+  c: double(1);
+  @include m(d);
+  @for $i from 1 through 2 { @debug $i; }
+  @if false { @debug "never"; }
+}
+`
+	msgs, err := runDiagnostics(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := []string{
+		"2:1: DEBUG: 6px",
+		"3:1: WARNING: x is 3px",
+		"5:3: DEBUG: double",
+		"9:3: WARNING: m d",
+		"15:30: DEBUG: 1",
+		"15:30: DEBUG: 2",
+	}
+	if len(msgs) != len(e) {
+		t.Fatalf("got: %q wanted: %q", msgs, e)
+	}
+	for i := range e {
+		if msgs[i] != e[i] {
+			t.Errorf("got: %s wanted: %s", msgs[i], e[i])
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	in := `@mixin size($w) {
+  @if $w == auto {
+    @error "size can not be" $w;
+  }
+  width: $w;
+}
+a { @include size(1px); }
+b { @include size(auto); }
+`
+	_, err := runDiagnostics(in)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if e := "3:5: size can not be auto"; err.Error() != e {
+		t.Errorf("got: %s wanted: %s", err, e)
+	}
+}
+
+func TestError_func(t *testing.T) {
+	in := `@function half($n) {
+  @if $n < 0 { @error "negative"; }
+  @return $n / 2;
+}
+a { b: half(-2); }
+`
+	_, err := runDiagnostics(in)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	// reported at the directive, not at the call
+	if e := "2:16: negative"; err.Error() != e {
+		t.Errorf("got: %s wanted: %s", err, e)
+	}
+}
+
+func TestDebug_mixinInterp(t *testing.T) {
+	in := `@mixin m($x) {
+  @debug "x is #{$x}";
+  @if $x == bad {
+    @error "bad #{$x}";
+  }
+}
+a { @include m(1); }
+b { @include m(bad); }
+`
+	msgs, err := runDiagnostics(in)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if e := "4:5: bad bad"; err.Error() != e {
+		t.Errorf("got: %s wanted: %s", err, e)
+	}
+	if e := "2:3: DEBUG: x is 1"; len(msgs) == 0 || msgs[0] != e {
+		t.Errorf("got: %q wanted: %s", msgs, e)
+	}
+}
+
+func TestDebug_funcLoop(t *testing.T) {
+	in := `@function f($n) {
+  @debug "f #{$n}";
+  @return $n;
+}
+a {
+  b: f(0);
+  @for $i from 1 through 2 { c: f($i); }
+}
+`
+	msgs, err := runDiagnostics(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := []string{
+		"2:3: DEBUG: f 0",
+		"2:3: DEBUG: f 1",
+		"2:3: DEBUG: f 2",
+	}
+	if strings.Join(msgs, "\n") != strings.Join(e, "\n") {
+		t.Errorf("got: %q wanted: %q", msgs, e)
+	}
+}
+
+func TestDebug_inspect(t *testing.T) {
+	in := `$m: (a: 1, b: 2);
+$l: (a b) (c d);
+@debug $m;
+@debug null;
+@debug $l;
+@debug (a,);
+@debug [a b];
+@debug "s";
+@function f($v) {
+  @debug $v;
+  @return 1;
+}
+a { b: f((c: 3)); }
+`
+	msgs, err := runDiagnostics(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := []string{
+		"3:1: DEBUG: (a: 1, b: 2)",
+		"4:1: DEBUG: null",
+		"5:1: DEBUG: (a b) (c d)",
+		"6:1: DEBUG: (a,)",
+		"7:1: DEBUG: [a b]",
+		"8:1: DEBUG: s",
+		"10:3: DEBUG: (c: 3)",
+	}
+	if strings.Join(msgs, "\n") != strings.Join(e, "\n") {
+		t.Errorf("got: %q wanted: %q", msgs, e)
+	}
+}
@@ -1,6 +1,9 @@
 package compiler
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/wellington/sass/parser"
@@ -73,3 +76,69 @@ div {
 		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
 	}
 }
+
+func TestDecl_default(t *testing.T) {
+	in := `$a: 1;
+$a: 2 !default;
+$b: 3 !default;
+$c: null;
+$c: 4 !default;
+div {
+  $a: 5 !global !default;
+  $d: 6 !default;
+  $b: 7 !global !default;
+  v: $a $b $c $d;
+}
+`
+	e := `div {
+  v: 1 3 4 6; }
+`
+	runParse(t, in, e)
+}
+
+func TestDecl_default_import(t *testing.T) {
+	dir, err := ioutil.TempDir("", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := `$primary: blue !default;
+$accent: red !default;
+.lib {
+  color: $primary;
+  background: $accent;
+}
+`
+	main := `$primary: purple;
+@import "lib";
+div {
+  color: $primary;
+}
+`
+	err = ioutil.WriteFile(filepath.Join(dir, "_lib.scss"), []byte(lib), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "main.scss")
+	err = ioutil.WriteFile(path, []byte(main), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := NewContext()
+	out, err := ctx.runString(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `.lib {
+  color: purple;
+  background: red; }
+
+div {
+  color: purple; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+}
@@ -57,3 +57,174 @@ func TestDirective_each(t *testing.T) {
 		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
 	}
 }
+
+func TestDirective_each_comma(t *testing.T) {
+	in := `div {
+  @each $i in a, b, c {
+    i: $i;
+  }
+  @each $i in (a b), (c d) {
+    j: $i;
+  }
+}
+`
+	e := `div {
+  i: a;
+  i: b;
+  i: c;
+  j: a b;
+  j: c d; }
+`
+	runParse(t, in, e)
+}
+
+func TestDirective_each_root(t *testing.T) {
+	in := `@each $i in a b {
+  .x-#{$i} { w: $i; }
+}
+`
+	e := `.x-a {
+  w: a; }
+
+.x-b {
+  w: b; }
+`
+	runParse(t, in, e)
+}
+
+func TestDirective_each_multiple(t *testing.T) {
+	in := `$icons: home "h", user "u";
+@each $name, $glyph in $icons {
+  .icon-#{$name} { content: $glyph; }
+}
+$theme: (primary: blue, accent: red);
+@mixin themed($map) {
+  @each $key, $value in $map {
+    color: $key $value;
+  }
+}
+@function sum($pairs) {
+  $t: 0;
+  @each $a, $b in $pairs {
+    $t: $t + $a * $b;
+  }
+  @return $t;
+}
+$pairs: 1 2, 3 4;
+div {
+  s: sum($pairs);
+  @include themed($theme);
+  @each $a, $b, $c in (x y), z {
+    v: $a inspect($b) inspect($c);
+  }
+}
+`
+	e := `.icon-home {
+  content: "h"; }
+
+.icon-user {
+  content: "u"; }
+
+div {
+  s: 14;
+  color: primary blue;
+  color: accent red;
+  v: x y null;
+  v: z null null; }
+`
+	runParse(t, in, e)
+}
+
+func TestDirective_atrule(t *testing.T) {
+	in := `@charset "UTF-8";
+@font-face {
+  font-family: x;
+  src: url(a.woff);
+}
+@foo bar;
+div {
+  @baz qux;
+}
+@page :first {
+  margin: 1in;
+  @top-center {
+    content: "x";
+  }
+}
+`
+	e := `@charset "UTF-8";
+
+@font-face {
+  font-family: x;
+  src: url(a.woff); }
+
+@foo bar;
+
+div {
+  @baz qux; }
+
+@page :first {
+  margin: 1in;
+  @top-center {
+    content: "x"; } }
+`
+	runParse(t, in, e)
+}
+
+func TestDirective_keyframes(t *testing.T) {
+	in := `$name: spin;
+div {
+  @keyframes #{$name}-x {
+    from, 10% { a: b; }
+    50% { c: d; }
+    to { e: f; }
+  }
+}
+`
+	e := `@keyframes spin-x {
+  from, 10% {
+    a: b; }
+  50% {
+    c: d; }
+  to {
+    e: f; } }
+`
+	runParse(t, in, e)
+}
+
+func TestDirective_supports(t *testing.T) {
+	in := `@mixin grid($n) {
+  @supports (grid-gap: #{$n}) {
+    gap: $n;
+  }
+}
+.a {
+  b: c;
+  @supports (display: grid) {
+    d: e;
+    .f { g: h; }
+  }
+}
+.i { @include grid(1px); }
+@supports (display: flex) {
+  .j { k: l; }
+}
+`
+	e := `.a {
+  b: c; }
+  @supports (display: grid) {
+    .a {
+      d: e; }
+      .a .f {
+        g: h; } }
+
+@supports (grid-gap: 1px) {
+  .i {
+    gap: 1px; } }
+
+@supports (display: flex) {
+  .j {
+    k: l; } }
+`
+	runParse(t, in, e)
+}
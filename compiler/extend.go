@@ -0,0 +1,703 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wellington/sass/ast"
+	"github.com/wellington/sass/token"
+)
+
+// compound is a sequence of simple selectors without combinators
+// ie. a.foo#bar:hover => [a .foo #bar :hover]
+type compound []string
+
+// selPart is a compound selector and the combinator preceding it.
+// The descendant combinator is represented by an empty string.
+type selPart struct {
+	comb string
+	sel  compound
+}
+
+// complexSel is a list of compound selectors joined by combinators
+// ie. a > b.c d
+type complexSel []selPart
+
+func (c compound) String() string {
+	return strings.Join(c, "")
+}
+
+func (c compound) has(simple string) bool {
+	for i := range c {
+		if c[i] == simple {
+			return true
+		}
+	}
+	return false
+}
+
+func (c complexSel) String() string {
+	parts := make([]string, 0, 2*len(c))
+	for i := range c {
+		if len(c[i].comb) > 0 {
+			parts = append(parts, c[i].comb)
+		}
+		parts = append(parts, c[i].sel.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// placeholder reports whether the selector contains a placeholder
+// selector ie. %foo
+func (c complexSel) placeholder() bool {
+	for _, part := range c {
+		for _, simple := range part.sel {
+			if simple[0] == '%' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dropPlaceholders removes placeholder selectors from the output. If
+// no selectors remain, nil is returned.
+func dropPlaceholders(lit *ast.BasicLit) *ast.BasicLit {
+	if lit == nil || !strings.Contains(lit.Value, "%") {
+		return lit
+	}
+	var sels []string
+	for _, s := range splitSelectors(lit.Value) {
+		if !parseComplex(s).placeholder() {
+			sels = append(sels, s)
+		}
+	}
+	if len(sels) == 0 {
+		return nil
+	}
+	return &ast.BasicLit{
+		Kind:     lit.Kind,
+		ValuePos: lit.ValuePos,
+		Value:    strings.Join(sels, ", "),
+	}
+}
+
+// splitSelectors splits a selector list by its commas, ignoring
+// commas found inside brackets or parens
+func splitSelectors(s string) []string {
+	var ret []string
+	var depth, last int
+	for i, r := range s {
+		switch r {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				ret = append(ret, strings.TrimSpace(s[last:i]))
+				last = i + 1
+			}
+		}
+	}
+	return append(ret, strings.TrimSpace(s[last:]))
+}
+
+// parseComplex breaks a single selector into compounds and combinators
+func parseComplex(s string) complexSel {
+	var (
+		sel  complexSel
+		cur  compound
+		comb string
+	)
+	flush := func() {
+		if len(cur) > 0 || len(comb) > 0 {
+			sel = append(sel, selPart{comb: comb, sel: cur})
+		}
+		cur, comb = nil, ""
+	}
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if len(cur) > 0 {
+				flush()
+			}
+			i++
+			continue
+		case ch == '>' || ch == '+' || ch == '~':
+			if len(cur) > 0 {
+				flush()
+			}
+			comb = string(ch)
+			i++
+			continue
+		}
+		// simple selector, scan until the next simple selector starts
+		start := i
+		i++
+		if ch == ':' && i < len(s) && s[i] == ':' {
+			i++
+		}
+		var depth int
+		if ch == '[' {
+			depth = 1
+		}
+		for ; i < len(s); i++ {
+			c := s[i]
+			if depth > 0 {
+				switch c {
+				case '(', '[':
+					depth++
+				case ')', ']':
+					depth--
+				}
+				continue
+			}
+			if c == '(' {
+				depth++
+				continue
+			}
+			if strings.IndexByte(".#%:[ \t\n>+~*", c) > -1 {
+				break
+			}
+		}
+		cur = append(cur, s[start:i])
+	}
+	flush()
+	return sel
+}
+
+func isTypeSel(simple string) bool {
+	if len(simple) == 0 {
+		return false
+	}
+	return strings.IndexByte(".#%:[", simple[0]) == -1
+}
+
+func isPseudoElement(simple string) bool {
+	switch simple {
+	case ":before", ":after", ":first-line", ":first-letter":
+		return true
+	}
+	return strings.HasPrefix(simple, "::")
+}
+
+// unifyType merges two type selectors, returning false when
+// they can never match the same element
+func unifyType(a, b string) (string, bool) {
+	switch {
+	case a == b, b == "*":
+		return a, true
+	case a == "*":
+		return b, true
+	}
+	return "", false
+}
+
+// unifySimple adds simple to the compound selector. It returns false
+// if the resulting selector can never match an element.
+func unifySimple(simple string, c compound) (compound, bool) {
+	if c.has(simple) {
+		return c, true
+	}
+	if isTypeSel(simple) {
+		if len(c) > 0 && isTypeSel(c[0]) {
+			typ, ok := unifyType(simple, c[0])
+			if !ok {
+				return nil, false
+			}
+			return append(compound{typ}, c[1:]...), true
+		}
+		return append(compound{simple}, c...), true
+	}
+	if len(c) == 1 && c[0] == "*" {
+		return compound{simple}, true
+	}
+
+	isElement := isPseudoElement(simple)
+	for _, s := range c {
+		if simple[0] == '#' && s[0] == '#' {
+			return nil, false
+		}
+		if isElement && isPseudoElement(s) {
+			return nil, false
+		}
+	}
+
+	ret := make(compound, 0, len(c)+1)
+	var added bool
+	for _, s := range c {
+		if !added && s[0] == ':' && (!strings.HasPrefix(simple, ":") ||
+			isPseudoElement(s)) {
+			ret = append(ret, simple)
+			added = true
+		}
+		ret = append(ret, s)
+	}
+	if !added {
+		ret = append(ret, simple)
+	}
+	return ret, true
+}
+
+// unifyCompound combines the simple selectors of two compounds
+func unifyCompound(a, b compound) (compound, bool) {
+	ret := append(compound{}, b...)
+	for _, simple := range a {
+		var ok bool
+		ret, ok = unifySimple(simple, ret)
+		if !ok {
+			return nil, false
+		}
+	}
+	return ret, true
+}
+
+// An extension records one @extend, the selector that requested it
+// and the media query it was found in.
+type extension struct {
+	pos      token.Pos
+	target   compound
+	extender complexSel
+	optional bool
+	media    string
+	matched  bool
+	// extends can not match across media queries
+	crossMedia bool
+}
+
+// matches reports whether the compound contains every simple
+// selector of the extend target. The remaining selectors are returned.
+func (e *extension) matches(c compound) (compound, bool) {
+	for _, t := range e.target {
+		if !c.has(t) {
+			return nil, false
+		}
+	}
+	rest := make(compound, 0, len(c))
+	for _, s := range c {
+		if !e.target.has(s) {
+			rest = append(rest, s)
+		}
+	}
+	return rest, true
+}
+
+// weave merges the parents of the extender and the parents of the
+// extended selector, prefix. last is the unified compound, its
+// combinator links it to prefix.
+func weave(prefix complexSel, ext complexSel, last selPart,
+	suffix complexSel) []complexSel {
+
+	extLast := ext[len(ext)-1]
+	var ret []complexSel
+	for _, w := range weaveTrailing(prefix, last.comb,
+		ext[:len(ext)-1], extLast.comb) {
+
+		sel := append(complexSel{}, w.sel...)
+		sel = append(sel, selPart{comb: w.comb, sel: last.sel})
+		ret = append(ret, append(sel, suffix...))
+	}
+	return ret
+}
+
+// woven is a merged parent selector and the combinator linking it to
+// the compound it is the parent of
+type woven struct {
+	sel  complexSel
+	comb string
+}
+
+// appendParent adds part to each of the woven parents, comb links
+// part to the compound following it
+func appendParent(ws []woven, part compound, comb string) []woven {
+	ret := make([]woven, 0, len(ws))
+	for _, w := range ws {
+		sel := append(complexSel{}, w.sel...)
+		ret = append(ret, woven{
+			sel:  append(sel, selPart{comb: w.comb, sel: part}),
+			comb: comb,
+		})
+	}
+	return ret
+}
+
+// weaveTrailing merges a and b, the parents of the same compound,
+// ca and cb link them to it. Parents linked by a child or sibling
+// combinator stay next to the compound, those of the same element are
+// unified.
+func weaveTrailing(a complexSel, ca string, b complexSel, cb string) []woven {
+	switch {
+	case len(a) == 0:
+		return []woven{{b, cb}}
+	case len(b) == 0:
+		return []woven{{a, ca}}
+	case len(ca) == 0 && len(cb) == 0:
+		var ret []woven
+		for _, sel := range weaveDescendants(a, b) {
+			ret = append(ret, woven{sel: sel})
+		}
+		return ret
+	}
+
+	la, lb := a[len(a)-1], b[len(b)-1]
+	pa, pb := a[:len(a)-1], b[:len(b)-1]
+	switch {
+	case len(cb) == 0:
+		return appendParent(weaveTrailing(pa, la.comb, b, cb), la.sel, ca)
+	case len(ca) == 0:
+		return appendParent(weaveTrailing(a, ca, pb, lb.comb), lb.sel, cb)
+	case ca == ">" && cb != ">":
+		// the sibling b shares the parent a
+		return appendParent(weaveTrailing(a, ca, pb, lb.comb), lb.sel, cb)
+	case cb == ">" && ca != ">":
+		return appendParent(weaveTrailing(pa, la.comb, b, cb), la.sel, ca)
+	}
+
+	var ret []woven
+	switch {
+	case ca == "~" && cb == "~":
+		ret = append(ret, appendParent(
+			weaveTrailing(a, ca, pb, lb.comb), lb.sel, cb)...)
+		ret = append(ret, appendParent(
+			weaveTrailing(pa, la.comb, b, cb), la.sel, ca)...)
+	case ca == "~":
+		// the general sibling a precedes the adjacent sibling b
+		ret = append(ret, appendParent(
+			weaveTrailing(a, ca, pb, lb.comb), lb.sel, cb)...)
+	case cb == "~":
+		ret = append(ret, appendParent(
+			weaveTrailing(pa, la.comb, b, cb), la.sel, ca)...)
+	}
+	// the same parent or sibling matched by both
+	if u, ok := unifyCompound(la.sel, lb.sel); ok {
+		comb := ca
+		if ca == "~" {
+			comb = cb
+		}
+		ret = append(ret, appendParent(
+			weaveTrailing(pa, la.comb, pb, lb.comb), u, comb)...)
+	}
+	return ret
+}
+
+// descendantGroups splits sel at its descendant combinators, compounds
+// joined by other combinators stay in the same group
+func descendantGroups(sel complexSel) []complexSel {
+	var groups []complexSel
+	for i, part := range sel {
+		if i == 0 || len(part.comb) == 0 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], part)
+	}
+	return groups
+}
+
+// commonGroups returns the indexes of the longest common subsequence
+// of the groups a and b
+func commonGroups(a, b []complexSel) [][2]int {
+	lens := make([][]int, len(a)+1)
+	for i := range lens {
+		lens[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i].String() == b[j].String():
+				lens[i][j] = lens[i+1][j+1] + 1
+			case lens[i+1][j] >= lens[i][j+1]:
+				lens[i][j] = lens[i+1][j]
+			default:
+				lens[i][j] = lens[i][j+1]
+			}
+		}
+	}
+	var ret [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i].String() == b[j].String():
+			ret = append(ret, [2]int{i, j})
+			i++
+			j++
+		case lens[i+1][j] >= lens[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return ret
+}
+
+// weaveDescendants merges the descendant parents a and b. Parents
+// shared by both are kept once, the others are interleaved in either
+// order.
+func weaveDescendants(a, b complexSel) []complexSel {
+	ga, gb := descendantGroups(a), descendantGroups(b)
+	flat := func(groups []complexSel) complexSel {
+		var ret complexSel
+		for _, g := range groups {
+			ret = append(ret, g...)
+		}
+		return ret
+	}
+	rets := []complexSel{nil}
+	add := func(options ...complexSel) {
+		var next []complexSel
+		for _, r := range rets {
+			for _, o := range options {
+				sel := append(complexSel{}, r...)
+				next = append(next, append(sel, o...))
+			}
+		}
+		rets = next
+	}
+	chunks := func(ca, cb complexSel) {
+		switch {
+		case len(ca) == 0:
+			add(cb)
+		case len(cb) == 0:
+			add(ca)
+		default:
+			add(append(append(complexSel{}, ca...), cb...),
+				append(append(complexSel{}, cb...), ca...))
+		}
+	}
+	var i, j int
+	for _, c := range commonGroups(ga, gb) {
+		chunks(flat(ga[i:c[0]]), flat(gb[j:c[1]]))
+		add(ga[c[0]])
+		i, j = c[0]+1, c[1]+1
+	}
+	chunks(flat(ga[i:]), flat(gb[j:]))
+	return rets
+}
+
+// extendComplex returns every selector produced by applying the
+// extensions to sel. Extensions are applied recursively so chained
+// extends are resolved.
+func (ctx *Context) extendComplex(sel complexSel, media string,
+	seen map[string]bool) []complexSel {
+
+	var ret []complexSel
+	for i, part := range sel {
+		for _, e := range ctx.extends {
+			rest, ok := e.matches(part.sel)
+			if !ok {
+				continue
+			}
+			if len(e.media) > 0 && e.media != media {
+				e.crossMedia = true
+				continue
+			}
+			e.matched = true
+			last := e.extender[len(e.extender)-1]
+			unified, ok := unifyCompound(last.sel, rest)
+			if !ok {
+				continue
+			}
+			for _, x := range weave(sel[:i], e.extender,
+				selPart{comb: part.comb, sel: unified}, sel[i+1:]) {
+
+				s := x.String()
+				if seen[s] {
+					continue
+				}
+				seen[s] = true
+				ret = append(ret, x)
+				ret = append(ret, ctx.extendComplex(x, media, seen)...)
+			}
+		}
+	}
+	return ret
+}
+
+// extendSel applies all extensions to a resolved selector. The
+// original lit is returned if no extension applies.
+func (ctx *Context) extendSel(lit *ast.BasicLit, media string) *ast.BasicLit {
+	if lit == nil || len(ctx.extends) == 0 {
+		return lit
+	}
+	var (
+		sels    []string
+		changed bool
+	)
+	seen := make(map[string]bool)
+	for _, s := range splitSelectors(lit.Value) {
+		seen[parseComplex(s).String()] = true
+	}
+	for _, s := range splitSelectors(lit.Value) {
+		sels = append(sels, s)
+		for _, x := range ctx.extendComplex(parseComplex(s), media, seen) {
+			sels = append(sels, x.String())
+			changed = true
+		}
+	}
+	if !changed {
+		return lit
+	}
+	return &ast.BasicLit{
+		Kind:     lit.Kind,
+		ValuePos: lit.ValuePos,
+		Value:    strings.Join(sels, ", "),
+	}
+}
+
+// extendedSel returns the selector lit was rewritten to by @extend
+func (ctx *Context) extendedSel(lit *ast.BasicLit) *ast.BasicLit {
+	for stmt, sel := range ctx.extended {
+		if stmt.Resolved == lit {
+			return sel
+		}
+	}
+	return lit
+}
+
+// collectExtends walks the statements recording every @extend
+// and the selectors and media queries they belong to.
+func (ctx *Context) collectExtends(stmts []ast.Stmt, parent *ast.SelStmt,
+	media string, rules map[*ast.SelStmt]string) error {
+
+	for _, stmt := range stmts {
+		switch v := stmt.(type) {
+		case *ast.SelStmt:
+			rules[v] = media
+			if err := ctx.collectExtends(v.Body.List, v, media, rules); err != nil {
+				return err
+			}
+		case *ast.MediaStmt:
+			if v.Query == nil {
+				continue
+			}
+			err := ctx.collectExtends(v.Body.List, parent, v.Query.Value, rules)
+			if err != nil {
+				return err
+			}
+		case *ast.EachStmt:
+			if err := ctx.collectExtends(v.Body.List, parent, media, rules); err != nil {
+				return err
+			}
+		case *ast.ForStmt:
+			if err := ctx.collectExtends(v.Body.List, parent, media, rules); err != nil {
+				return err
+			}
+		case *ast.WhileStmt:
+			if err := ctx.collectExtends(v.Body.List, parent, media, rules); err != nil {
+				return err
+			}
+		case *ast.AtRootStmt:
+			if err := ctx.collectExtends(v.Body.List, parent, media, rules); err != nil {
+				return err
+			}
+		case *ast.DirectiveStmt:
+			if v.Body == nil {
+				continue
+			}
+			if err := ctx.collectExtends(v.Body.List, parent, media, rules); err != nil {
+				return err
+			}
+		case *ast.BlockStmt:
+			if err := ctx.collectExtends(v.List, parent, media, rules); err != nil {
+				return err
+			}
+		case *ast.IfStmt:
+			ok, err := ctx.truthy(v.Cond)
+			if err != nil {
+				return err
+			}
+			branch := v.Else
+			if ok {
+				branch = v.Body
+			}
+			if branch == nil {
+				continue
+			}
+			err = ctx.collectExtends([]ast.Stmt{branch}, parent, media, rules)
+			if err != nil {
+				return err
+			}
+		case *ast.ExtendStmt:
+			if parent == nil {
+				return ctx.errorf(v.Pos(),
+					"@extend may only be used within style rules")
+			}
+			for _, target := range splitSelectors(v.Sel.Value) {
+				sel := parseComplex(target)
+				if len(sel) != 1 || len(sel[0].comb) > 0 {
+					return ctx.errorf(v.Pos(),
+						"can't extend complex selector %s", target)
+				}
+				for _, ext := range splitSelectors(parent.Resolved.Value) {
+					ctx.extends = append(ctx.extends, &extension{
+						pos:      v.Pos(),
+						target:   sel[0].sel,
+						extender: parseComplex(ext),
+						optional: v.Optional,
+						media:    media,
+					})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// extend collects all @extend in the file and rewrites the selectors
+// they target.
+func (ctx *Context) extend(f *ast.File) error {
+	var stmts []ast.Stmt
+	for _, decl := range f.Decls {
+		switch v := decl.(type) {
+		case *ast.SelDecl:
+			stmts = append(stmts, v.SelStmt)
+		case *ast.IfDecl:
+			stmts = append(stmts, v.IfStmt)
+		case *ast.EachDecl:
+			stmts = append(stmts, v.EachStmt)
+		case *ast.ForDecl:
+			stmts = append(stmts, v.ForStmt)
+		case *ast.WhileDecl:
+			stmts = append(stmts, v.WhileStmt)
+		case *ast.DirectiveDecl:
+			stmts = append(stmts, v.DirectiveStmt)
+		case *ast.MediaDecl:
+			stmts = append(stmts, v.MediaStmt)
+		case *ast.AtRootDecl:
+			stmts = append(stmts, v.AtRootStmt)
+		}
+	}
+	rules := make(map[*ast.SelStmt]string)
+	if err := ctx.collectExtends(stmts, nil, "", rules); err != nil {
+		return err
+	}
+	if len(ctx.extends) == 0 {
+		return nil
+	}
+
+	ctx.extended = make(map[*ast.SelStmt]*ast.BasicLit)
+	for stmt, media := range rules {
+		ctx.extended[stmt] = ctx.extendSel(stmt.Resolved, media)
+	}
+
+	for _, e := range ctx.extends {
+		switch {
+		case e.matched || e.optional:
+		case e.crossMedia:
+			return ctx.errorf(e.pos,
+				"you may not @extend selectors across media queries")
+		default:
+			return ctx.errorf(e.pos,
+				"the target selector was not found.\n"+
+					"Use \"@extend %s !optional\" to avoid this error.",
+				e.target)
+		}
+	}
+	return nil
+}
+
+// errorf reports an error at the position in the compiled file
+func (ctx *Context) errorf(pos token.Pos, format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s", ctx.fset.Position(pos),
+		fmt.Sprintf(format, args...))
+}
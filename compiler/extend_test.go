@@ -0,0 +1,231 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wellington/sass/parser"
+	"github.com/wellington/sass/token"
+)
+
+func TestExtend_simple(t *testing.T) {
+	in := `.btn { color: red; }
+.primary {
+  @extend .btn;
+  font: bold;
+}
+`
+	e := `.btn, .primary {
+  color: red; }
+
+.primary {
+  font: bold; }
+`
+	runParse(t, in, e)
+}
+
+func TestExtend_compound(t *testing.T) {
+	in := `a.foo { x: y; }
+.bar { @extend .foo; }
+div { @extend .foo; }
+.baz { @extend .bar.foo; }
+`
+	e := `a.foo, a.bar {
+  x: y; }
+`
+	ctx := NewContext()
+	ctx.fset = token.NewFileSet()
+	_, err := ctx.runString("", in)
+	if err == nil {
+		t.Fatal("expected error for unmatched .bar.foo")
+	}
+
+	runParse(t, strings.Replace(in, ".bar.foo;", ".bar.foo !optional;", 1), e)
+}
+
+func TestExtend_complex(t *testing.T) {
+	in := `.a .b { c: d; }
+.c .d { @extend .b; }
+`
+	e := `.a .b, .a .c .d, .c .a .d {
+  c: d; }
+`
+	runParse(t, in, e)
+}
+
+func TestExtend_chained(t *testing.T) {
+	in := `.b { x: y; }
+.a { @extend .b; }
+.c { @extend .a; }
+.d, .e { @extend .c; }
+`
+	e := `.b, .a, .c, .d, .e {
+  x: y; }
+`
+	runParse(t, in, e)
+}
+
+func TestExtend_media(t *testing.T) {
+	in := `div {
+  @media print {
+    .b { x: y; }
+  }
+}
+.c {
+  @media print { @extend .b; }
+}
+`
+	e := `@media print {
+  div .b, div .c {
+    x: y; } }
+`
+	runParse(t, in, e)
+
+	ctx := NewContext()
+	ctx.fset = token.NewFileSet()
+	_, err := ctx.runString("", `.b { x: y; }
+div { @media print { .c { @extend .b; } } }
+`)
+	if err == nil {
+		t.Fatal("expected cross media error")
+	}
+	e = "2:27: you may not @extend selectors across media queries"
+	if err.Error() != e {
+		t.Fatalf("got: %s\nwanted: %s", err, e)
+	}
+}
+
+func TestExtend_notfound(t *testing.T) {
+	ctx := NewContext()
+	ctx.fset = token.NewFileSet()
+	_, err := ctx.runString("", `.a {
+  @extend .missing;
+}
+`)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	e := `2:3: the target selector was not found.
+Use "@extend .missing !optional" to avoid this error.`
+	if err.Error() != e {
+		t.Fatalf("got: %s\nwanted: %s", err, e)
+	}
+
+	runParse(t, `.a { @extend .missing !optional; }`, "")
+}
+
+func TestExtend_placeholder(t *testing.T) {
+	in := `%btn { color: red; }
+.primary {
+  @extend %btn;
+}
+%unused { x: y; }
+`
+	e := `.primary {
+  color: red; }
+`
+	runParse(t, in, e)
+}
+
+func TestExtend_placeholder_nested(t *testing.T) {
+	in := `.a, %b {
+  x: y;
+  .c { d: e; }
+}
+%f .g { h: i; }
+%k { /* hidden */ }
+.j { @extend %f; }
+`
+	e := `.a {
+  x: y; }
+  .a .c {
+    d: e; }
+
+.j .g {
+  h: i; }
+`
+	runParse(t, in, e)
+}
+
+func TestExtend_nested(t *testing.T) {
+	in := `.p {
+  .a { @extend .b; }
+  .b { w: 1; }
+}
+`
+	e := `.p .b, .p .a {
+  w: 1; }
+`
+	runParse(t, in, e)
+}
+
+func TestExtend_sharedParents(t *testing.T) {
+	in := `.p .q .b { w: 1; }
+.p .a { @extend .b; }
+`
+	e := `.p .q .b, .p .q .a {
+  w: 1; }
+`
+	runParse(t, in, e)
+}
+
+func TestExtend_combinators(t *testing.T) {
+	runParse(t, `.a > .c { w: 1; }
+.x .b { @extend .c; }
+`, `.a > .c, .x .a > .b {
+  w: 1; }
+`)
+	runParse(t, `.a + .c { w: 1; }
+.x ~ .b { @extend .c; }
+`, `.a + .c, .x ~ .a + .b, .x.a + .b {
+  w: 1; }
+`)
+	runParse(t, `.a > .c { w: 1; }
+.x > .b { @extend .c; }
+`, `.a > .c, .x.a > .b {
+  w: 1; }
+`)
+	runParse(t, `.a .c { w: 1; }
+.x + .b { @extend .c; }
+`, `.a .c, .a .x + .b {
+  w: 1; }
+`)
+}
+
+func TestExtend_nestedImport(t *testing.T) {
+	files := map[string][]byte{
+		"_part.scss": []byte(`.a { @extend .b; }
+.b { w: 1; }
+`),
+		"main.scss": []byte(`.t {
+  @import "part";
+}
+`),
+	}
+	ctx := NewContext()
+	ctx.SetImporter(parser.NewMapImporter(files))
+	out, err := ctx.Run("main.scss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `.t .b, .t .a {
+  w: 1; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+}
+
+func TestExtend_if(t *testing.T) {
+	in := `.a { x: y; }
+$on: yes;
+.b { @if 1 { @extend .a; } }
+.c { @if $on { @extend .a; } }
+.d { @if null { @extend .a; } }
+.e { @if false { @extend .missing; } @else { @extend .a; } }
+`
+	e := `.a, .b, .c, .e {
+  x: y; }
+`
+	runParse(t, in, e)
+}
@@ -0,0 +1,156 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/wellington/sass/token"
+)
+
+func TestFor_through(t *testing.T) {
+	in := `div {
+  @for $i from 1 through 3 {
+    i: $i;
+  }
+}
+`
+	e := `div {
+  i: 1;
+  i: 2;
+  i: 3; }
+`
+	runParse(t, in, e)
+}
+
+func TestFor_to(t *testing.T) {
+	in := `div {
+  @for $i from 1 to 3 {
+    i: $i;
+  }
+  @for $j from 3 to 1 {
+    j: $j;
+  }
+}
+`
+	e := `div {
+  i: 1;
+  i: 2;
+  j: 3;
+  j: 2; }
+`
+	runParse(t, in, e)
+}
+
+func TestFor_units(t *testing.T) {
+	in := `$n: 2;
+div {
+  @for $i from 1px through $n {
+    width: $i * 10;
+  }
+}
+`
+	e := `div {
+  width: 10px;
+  width: 20px; }
+`
+	runParse(t, in, e)
+}
+
+func TestFor_selectors(t *testing.T) {
+	in := `@for $i from 1 through 2 {
+  .col-#{$i} {
+    width: $i;
+  }
+}
+`
+	e := `.col-1 {
+  width: 1; }
+
+.col-2 {
+  width: 2; }
+`
+	runParse(t, in, e)
+}
+
+func TestFor_mixin(t *testing.T) {
+	in := `@mixin cols($n) {
+  @for $i from 1 through $n {
+    c: $i;
+  }
+}
+div {
+  @include cols(2);
+}
+`
+	e := `div {
+  c: 1;
+  c: 2; }
+`
+	runParse(t, in, e)
+}
+
+func TestFor_float(t *testing.T) {
+	ctx := NewContext()
+	ctx.fset = token.NewFileSet()
+	_, err := ctx.runString("", `div {
+  @for $i from 1.5 through 3 { i: $i; }
+}
+`)
+	if err == nil {
+		t.Fatal("expected error for non-integer bound")
+	}
+	if e := "2:16: 1.5 is not an integer"; err.Error() != e {
+		t.Errorf("got: %s wanted: %s", err, e)
+	}
+}
+
+func TestFor_nested(t *testing.T) {
+	in := `@for $i from 1 through 2 {
+  .a-#{$i} {
+    @for $j from 1 through 2 { x: $i $j; }
+  }
+}
+@each $c in r, g {
+  .b-#{$c} {
+    @each $d in 1, 2 { y: $c $d; }
+  }
+}
+`
+	e := `.a-1 {
+  x: 1 1;
+  x: 1 2; }
+
+.a-2 {
+  x: 2 1;
+  x: 2 2; }
+
+.b-r {
+  y: r 1;
+  y: r 2; }
+
+.b-g {
+  y: g 1;
+  y: g 2; }
+`
+	runParse(t, in, e)
+}
+
+func TestFor_accumulate(t *testing.T) {
+	in := `$sum: 0;
+@for $i from 1 through 3 {
+  $sum: $sum + $i;
+}
+$t: 0;
+@each $v in 1 2 3 {
+  @for $k from 1 through 2 { $t: $t + $v * $k; }
+}
+div {
+  sum: $sum;
+  t: $t;
+}
+`
+	e := `div {
+  sum: 6;
+  t: 18; }
+`
+	runParse(t, in, e)
+}
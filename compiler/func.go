@@ -20,14 +20,12 @@ func visitFunc(ctx *Context, n ast.Node) {
 		// 	ast.Walk(&mixctx, l)
 		// }
 
-		ctx.scope.RegisterMixin(fn.Name.String(),
-			fn.Type.Params.NumFields(),
-			&MixFn{
-				minArgs: fn.Type.Params.NumFields(),
-				ctx:     &mixctx,
-				fn:      fn,
-			})
+		ctx.scope.RegisterMixin(fn.Name.String(), &MixFn{
+			ctx: &mixctx,
+			fn:  fn,
+		})
 	case token.FUNC:
+		// Functions are evaluated by the parser where they are called
 	default:
 		fmt.Printf("% #v\n", fn)
 		panic("unsupported visitFunc")
@@ -0,0 +1,210 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/wellington/sass/token"
+)
+
+func TestFunc_locals(t *testing.T) {
+	in := `$base: 4px;
+@function space($n) {
+  $x: $base * $n;
+  @return $x;
+}
+@function inset($n, $by: 1) {
+  @return space($n) - space($by);
+}
+// keyword arguments replace the default of their parameter
+@function shift($n, $by: 1) {
+  @return $n - $by;
+}
+div {
+  a: space(2);
+  b: inset(3);
+  c: inset($by: 2, $n: 4);
+  d: inset(2) / 2;
+  e: shift(3, $by: 2);
+}
+`
+	e := `div {
+  a: 8px;
+  b: 8px;
+  c: 8px;
+  d: 2px;
+  e: 1; }
+`
+	runParse(t, in, e)
+}
+
+func TestFunc_control(t *testing.T) {
+	in := `@function sign($n) {
+  @if $n > 0 {
+    @return pos;
+  } @else if $n < 0 {
+    @return neg;
+  }
+  @return zero;
+}
+@function sum($list) {
+  $s: 0;
+  @each $i in $list {
+    $s: $s + $i;
+  }
+  @return $s;
+}
+@function tri($n) {
+  $s: 0;
+  @for $i from 1 through $n {
+    @if $i > 3 {
+      @return $s;
+    }
+    $s: $s + $i;
+  }
+  @return $s;
+}
+@function fact($n) {
+  @if $n <= 1 {
+    @return 1;
+  }
+  @return $n * fact($n - 1);
+}
+div {
+  a: sign(3) sign(-1) sign(0);
+  b: sum(1 2 3);
+  c: tri(2) tri(10);
+  d: fact(5);
+}
+`
+	e := `div {
+  a: pos neg zero;
+  b: 6;
+  c: 3 6;
+  d: 120; }
+`
+	runParse(t, in, e)
+}
+
+func TestFunc_callers(t *testing.T) {
+	in := `@function double($n) {
+  @return $n * 2;
+}
+$x: double(2px);
+@mixin pad($n) {
+  padding: double($n);
+}
+@for $i from 1 through 2 {
+  .m-#{double($i)} {
+    margin: double($i);
+  }
+}
+.a {
+  w: $x;
+  @include pad(3px);
+}
+`
+	e := `.m-2 {
+  margin: 2; }
+
+.m-4 {
+  margin: 4; }
+
+.a {
+  w: 4px;
+  padding: 6px; }
+`
+	runParse(t, in, e)
+}
+
+func TestFunc_noreturn(t *testing.T) {
+	ctx := NewContext()
+	ctx.fset = token.NewFileSet()
+	_, err := ctx.runString("", `@function f() {
+  $x: 1;
+}
+div { a: f(); }
+`)
+	if e := "4:11: Function f finished without @return"; err == nil || err.Error() != e {
+		t.Errorf("got: %v wanted: %s", err, e)
+	}
+}
+
+func TestFunc_varargs(t *testing.T) {
+	in := `@function sum($nums...) {
+  $s: 0;
+  @each $n in $nums {
+    $s: $s + $n;
+  }
+  @return $s;
+}
+@function count($first, $rest...) {
+  @return length($rest);
+}
+$sizes: 1px, 2px, 3px;
+$pad: 10px;
+div {
+  a: sum(1, 2, 3);
+  b: sum($sizes...);
+  c: count(a, b, c);
+  d: unit($pad...);
+}
+`
+	e := `div {
+  a: 6;
+  b: 6px;
+  c: 2;
+  d: "px"; }
+`
+	runParse(t, in, e)
+}
+
+func TestFunc_emptyVarargs(t *testing.T) {
+	in := `@function count($args...) {
+  @return length($args);
+}
+@function fg($args...) {
+  @return map-get(keywords($args), fg);
+}
+div {
+  a: count();
+  b: fg($fg: red, $bg: blue);
+  c: count($fg: red);
+}
+`
+	e := `div {
+  a: 0;
+  b: red;
+  c: 0; }
+`
+	runParse(t, in, e)
+}
+
+func TestFunc_interp(t *testing.T) {
+	in := `@function quoted($x) {
+  @return "v#{$x}";
+}
+@function px($x) {
+  @return #{$x}px;
+}
+@function local($x) {
+  $s: "v#{$x}";
+  @return $s;
+}
+@function pair($x) {
+  @return $x $x;
+}
+div {
+  a: quoted(1);
+  b: px(2);
+  c: local(3);
+  d: pair(4);
+}
+`
+	e := `div {
+  a: "v1";
+  b: 2px;
+  c: "v3";
+  d: 4 4; }
+`
+	runParse(t, in, e)
+}
@@ -0,0 +1,655 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/wellington/sass/parser"
+)
+
+func TestImport_plain(t *testing.T) {
+	in := `$bp: 600px;
+$theme: "dark";
+div {
+  color: red;
+}
+@import "a.css", url(b.css);
+@import "http://fonts.com/c";
+@import url("d") screen and (min-width: $bp + 1px);
+@import "#{$theme}";
+`
+	e := `@import "a.css";
+@import url(b.css);
+@import "http://fonts.com/c";
+@import url("d") screen and (min-width: 601px);
+@import "dark";
+div {
+  color: red; }
+`
+	runParse(t, in, e)
+}
+
+func TestImport_plainPath(t *testing.T) {
+	in := `@charset "UTF-8";
+$t: "dark";
+@import "/x/y.css";
+@import "/x/#{$t}/z";
+a {
+  color: red;
+}
+`
+	e := `@charset "UTF-8";
+@import "/x/y.css";
+@import "/x/dark/z";
+a {
+  color: red; }
+`
+	runParse(t, in, e)
+}
+
+func TestImport_absolute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "_partial.scss"),
+		[]byte(".p {\n  x: y;\n}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := `@import "` + filepath.ToSlash(filepath.Join(dir, "partial")) + `";
+`
+	e := `.p {
+  x: y; }
+`
+	runParse(t, in, e)
+}
+
+func TestImport_list(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"_a.scss": `.a {
+  x: y;
+}
+`,
+		"_b.scss": `.b {
+  x: z;
+}
+`,
+		"main.scss": `@import "a", "reset.css", "b";
+div {
+  color: red;
+}
+`,
+	}
+	for name, src := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := NewContext()
+	out, err := ctx.runString(filepath.Join(dir, "main.scss"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `@import "reset.css";
+.a {
+  x: y; }
+
+.b {
+  x: z; }
+
+div {
+  color: red; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+}
+
+func TestImport_includePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"shared/_vars.scss": `$gap: 4px;
+`,
+		"shared/base.css": `.base {
+  margin: 0;
+}
+`,
+		"shared/theme/_index.scss": `$color: red;
+`,
+		"app/main.scss": `@import "vars", "base";
+@use "theme";
+div {
+  padding: $gap;
+  color: theme.$color;
+}
+`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := NewContext()
+	ctx.SetImporter(parser.NewFileImporter(filepath.Join(dir, "shared")))
+	out, err := ctx.runString(filepath.Join(dir, "app/main.scss"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `.base {
+  margin: 0; }
+
+div {
+  padding: 4px;
+  color: red; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+}
+
+func TestImport_memory(t *testing.T) {
+	files := map[string][]byte{
+		"themes/main.scss": []byte(`@import "vars";
+@use "mixins";
+div {
+  color: $color;
+  @include mixins.pad(2px);
+}
+`),
+		"themes/_vars.scss": []byte(`$color: red;
+`),
+		"lib/_mixins.scss": []byte(`@mixin pad($x) { padding: $x; }
+`),
+	}
+	e := `div {
+  color: red;
+  padding: 2px; }
+`
+	importers := []parser.Importer{
+		parser.NewMapImporter(files, "lib"),
+		parser.NewFSImporter(fstest.MapFS{
+			"themes/main.scss":  {Data: files["themes/main.scss"]},
+			"themes/_vars.scss": {Data: files["themes/_vars.scss"]},
+			"lib/_mixins.scss":  {Data: files["lib/_mixins.scss"]},
+		}, "lib"),
+	}
+	for _, imp := range importers {
+		ctx := NewContext()
+		ctx.SetImporter(imp)
+		out, err := ctx.Run("themes/main.scss")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e != out {
+			t.Fatalf("%T got:\n%s\nwanted:\n%s", imp, out, e)
+		}
+	}
+
+	// positions of errors name the file of the importer
+	files["themes/_vars.scss"] = []byte(`$color: red;
+@error "no colors";
+`)
+	ctx := NewContext()
+	ctx.SetImporter(parser.NewMapImporter(files, "lib"))
+	_, err := ctx.Run("themes/main.scss")
+	if err == nil || !strings.HasPrefix(err.Error(), "themes/_vars.scss:2:") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestImport_nested(t *testing.T) {
+	files := map[string][]byte{
+		"_palette.scss": []byte(`$fg: white !default;
+$bg: black;
+a { color: $fg; }
+.btn { background: $bg; }
+`),
+		"_mixins.scss": []byte(`@mixin pad() { padding: 1px; }
+`),
+		"main.scss": []byte(`.theme-dark {
+  $fg: gray;
+  @import "palette";
+}
+.theme-light {
+  @import "palette";
+  color: $bg;
+}
+@mixin themed() {
+  @import "palette";
+}
+p {
+  $fg: red;
+  @include themed();
+}
+div {
+  @import "mixins";
+  @include pad();
+}
+`),
+		"leak.scss": []byte(`.t {
+  @import "palette";
+}
+div {
+  color: $bg;
+}
+`),
+		"leakmixin.scss": []byte(`.t {
+  @import "mixins";
+}
+.u {
+  @include pad();
+}
+`),
+	}
+	ctx := NewContext()
+	ctx.SetImporter(parser.NewMapImporter(files))
+	out, err := ctx.Run("main.scss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// variables of the palette do not leave the blocks importing it
+	e := `.theme-dark a {
+  color: gray; }
+
+.theme-dark .btn {
+  background: black; }
+
+.theme-light {
+  color: black; }
+  .theme-light a {
+    color: white; }
+  .theme-light .btn {
+    background: black; }
+
+p a {
+  color: red; }
+
+p .btn {
+  background: black; }
+
+div {
+  padding: 1px; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+
+	// variables and mixins of the import do not leave the block
+	for name, e := range map[string]string{
+		"leak.scss":      `leak.scss:5:10: Undefined variable: "$bg".`,
+		"leakmixin.scss": "leakmixin.scss:5:12: undefined mixin: pad",
+	} {
+		_, err := ctx.Run(name)
+		if err == nil || !strings.HasSuffix(err.Error(), e) {
+			t.Errorf("%s: got: %v wanted: %s", name, err, e)
+		}
+	}
+}
+
+func TestImport_globPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"shared/_a.scss": `.a { color: red; }
+`,
+		"lib/widgets/_w.scss": `.w { color: blue; }
+`,
+		"styles/main.scss": `@import "../shared/*", "widgets/*";
+`,
+		"styles/missing.scss": `@import "nope/*";
+`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := NewContext()
+	ctx.SetImporter(parser.NewFileImporter(filepath.Join(dir, "lib")))
+	out, err := ctx.Run(filepath.Join(dir, "styles/main.scss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `.a {
+  color: red; }
+
+.w {
+  color: blue; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+	// patterns are recorded for the directory they matched in
+	_, globs := ctx.Dependencies()
+	wanted := []string{
+		filepath.Join(dir, "shared/*"),
+		filepath.Join(dir, "lib/widgets/*"),
+	}
+	if strings.Join(globs, " ") != strings.Join(wanted, " ") {
+		t.Errorf("got globs: %q wanted: %q", globs, wanted)
+	}
+
+	missing := filepath.Join(dir, "styles/missing.scss")
+	_, err = ctx.Run(missing)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if e := missing + ":1:9: failed to import: file to import not found: nope/*"; err.Error() != e {
+		t.Errorf("got: %s\nwanted: %s", err, e)
+	}
+}
+
+func TestImport_loop(t *testing.T) {
+	files := map[string][]byte{
+		"main.scss": []byte(`@import "a";
+`),
+		"_a.scss": []byte(`a { b: c; }
+@import "b";
+`),
+		"_b.scss": []byte(`@import "a";
+`),
+		"self.scss": []byte(`@import "self";
+`),
+		"mixin.scss": []byte(`@mixin m() { @import "mixin"; }
+div { @include m(); }
+`),
+	}
+	table := []struct {
+		path string
+		err  string
+	}{
+		{"main.scss", "_b.scss:1:9: failed to import: import loop: " +
+			"main.scss:1:9 imports _a.scss, " +
+			"_a.scss:2:9 imports _b.scss, " +
+			"_b.scss:1:9 imports _a.scss"},
+		{"self.scss", "self.scss:1:9: failed to import: import loop: " +
+			"self.scss:1:9 imports self.scss"},
+		{"mixin.scss", "mixin.scss:1:22: failed to import: import loop: " +
+			"mixin.scss:1:22 imports mixin.scss"},
+	}
+	for _, tt := range table {
+		ctx := NewContext()
+		ctx.SetImporter(parser.NewMapImporter(files))
+		_, err := ctx.Run(tt.path)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.path)
+			continue
+		}
+		if err.Error() != tt.err {
+			t.Errorf("%s got: %s\nwanted: %s", tt.path, err, tt.err)
+		}
+	}
+}
+
+func TestImport_loopRelative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	src := `a { b: c; }
+@import "self";
+`
+	if err := ioutil.WriteFile("self.scss", []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the root is named by its absolute path like its imports
+	name, err := filepath.Abs("self.scss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []parser.Mode{0, parser.ImportOnce} {
+		ctx := NewContext()
+		ctx.SetMode(mode)
+		_, err := ctx.Run("self.scss")
+		if err == nil {
+			t.Errorf("mode %d: expected an error", mode)
+			continue
+		}
+		e := name + ":2:9: failed to import: import loop: " +
+			name + ":2:9 imports " + name
+		if err.Error() != e {
+			t.Errorf("mode %d got: %s\nwanted: %s", mode, err, e)
+		}
+	}
+}
+
+func TestImport_once(t *testing.T) {
+	files := map[string][]byte{
+		"main.scss": []byte(`@import "a", "mixins";
+@import "mixins";
+div { @include pad(); }
+`),
+		"_a.scss": []byte(`@import "mixins";
+a { @include pad(); }
+`),
+		"_mixins.scss": []byte(`@mixin pad() { padding: 1px; }
+.mixins { color: red; }
+`),
+	}
+	ctx := NewContext()
+	ctx.SetImporter(parser.NewMapImporter(files))
+	ctx.SetMode(parser.ImportOnce)
+	out, err := ctx.Run("main.scss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `.mixins {
+  color: red; }
+
+a {
+  padding: 1px; }
+
+div {
+  padding: 1px; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+	deps, _ := ctx.Dependencies()
+	if e := "_a.scss _mixins.scss"; strings.Join(deps, " ") != e {
+		t.Errorf("got deps: %q wanted: %s", deps, e)
+	}
+}
+
+func TestImport_roots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"secret/_key.scss": `$key: hunter2;
+`,
+		"tenant/main.scss": `div {
+  color: red;
+}
+@import "../secret/key";
+`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := NewContext()
+	ctx.SetAllowedRoots(filepath.Join(dir, "tenant"))
+	main := filepath.Join(dir, "tenant/main.scss")
+	_, err = ctx.Run(main)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	e := main + ":4:9: failed to import: ../secret/key is outside of the allowed roots"
+	if err.Error() != e {
+		t.Fatalf("got: %s\nwanted: %s", err, e)
+	}
+}
+
+func TestImport_rootsImporter(t *testing.T) {
+	ctx := NewContext()
+	ctx.SetImporter(parser.NewMapImporter(map[string][]byte{
+		"main.scss": []byte("div { color: red; }\n"),
+	}))
+	ctx.SetAllowedRoots("tenant")
+	_, err := ctx.Run("main.scss")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	e := "allowed roots are not supported by *parser.MapImporter"
+	if err.Error() != e {
+		t.Fatalf("got: %s\nwanted: %s", err, e)
+	}
+}
+
+func TestImport_glob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"components/_button.scss": `.button { color: red; }
+`,
+		"components/_alert.scss": `.alert { color: blue; }
+`,
+		"components/forms/_input.scss": `.input { color: green; }
+`,
+		"main.scss": `@import "components/**/*";
+div { color: white; }
+`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := NewContext()
+	out, err := ctx.Run(filepath.Join(dir, "main.scss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `.alert {
+  color: blue; }
+
+.button {
+  color: red; }
+
+.input {
+  color: green; }
+
+div {
+  color: white; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+
+	deps, globs := ctx.Dependencies()
+	var rel []string
+	for _, name := range deps {
+		r, err := filepath.Rel(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	if e := "components/_alert.scss components/_button.scss components/forms/_input.scss"; strings.Join(rel, " ") != e {
+		t.Errorf("got deps: %q wanted: %s", rel, e)
+	}
+	if e := filepath.Join(dir, "components/**/*"); len(globs) != 1 || globs[0] != e {
+		t.Errorf("got globs: %q wanted: %s", globs, e)
+	}
+}
+
+func TestImport_globAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"lib/_a.scss":    ".a { color: red; }\n",
+		"lib/b/_c.scss":  ".c { color: blue; }\n",
+		"lib/index.scss": "@import \"**/*\";\n",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := NewContext()
+	out, err := ctx.Run(filepath.Join(dir, "lib/index.scss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `.a {
+  color: red; }
+
+.c {
+  color: blue; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+}
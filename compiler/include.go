@@ -16,9 +16,7 @@ func printInclude(ctx *Context, n ast.Node) {
 	if spec.Params != nil {
 		params = spec.Params.List
 	}
-	numargs := spec.Params.NumFields()
-
-	mix, err := ctx.scope.Mixin(name, numargs)
+	mix, err := ctx.scope.Mixin(name)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,105 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wellington/sass/parser"
+)
+
+func TestIndented(t *testing.T) {
+	in := `// colors
+  of the theme
+$c: red
+$n: 2
+
+=box($w: 1px)
+  width: $w
+
+.a,
+.b
+  color: $c // trailing
+  +box(2px)
+  &.on
+    :color blue
+  @if $n == 1
+    x: y
+  @else
+    x: z
+`
+	ctx := NewContext()
+	ctx.SetMode(parser.Indented)
+	out, err := ctx.runString("", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `.a, .b {
+  color: red;
+  width: 2px;
+  x: z; }
+  .a.on, .b.on {
+    color: blue; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+}
+
+func TestIndented_dedent(t *testing.T) {
+	in := `a
+    b: c
+  d: e
+`
+	ctx := NewContext()
+	ctx.SetMode(parser.Indented)
+	_, err := ctx.runString("", in)
+	if err == nil || !strings.Contains(err.Error(), "3:3: inconsistent indentation") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestIndented_import(t *testing.T) {
+	dir, err := ioutil.TempDir("", "indented")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"_vars.scss": `$c: red;
+`,
+		"_lib.sass": `@import vars
+.lib
+  color: $c
+`,
+		"main.scss": `@import "lib";
+div {
+  color: $c;
+}
+`,
+	}
+	for name, src := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := NewContext()
+	out, err := ctx.runString(filepath.Join(dir, "main.scss"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `.lib {
+  color: red; }
+
+div {
+  color: red; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+}
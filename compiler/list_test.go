@@ -0,0 +1,125 @@
+package compiler
+
+import "testing"
+
+func TestList_nested(t *testing.T) {
+	in := `$space: (a b) (c d);
+$comma: (a b), (c d);
+$single: (a,);
+div {
+  a: $space;
+  b: $comma;
+  c: length($space);
+  d: length($comma);
+  e: length($single);
+  f: inspect($space);
+  g: inspect($comma);
+  h: inspect($single);
+  i: inspect(());
+  @each $row in $space {
+    row: nth($row, 2);
+  }
+}
+`
+	e := `div {
+  a: a b c d;
+  b: a b, c d;
+  c: 2;
+  d: 2;
+  e: 1;
+  f: (a b) (c d);
+  g: a b, c d;
+  h: (a,);
+  i: ();
+  row: b;
+  row: d; }
+`
+	runParse(t, in, e)
+}
+
+func TestList_bracketed(t *testing.T) {
+	in := `$lines: [full-start] 1fr [main-start];
+div {
+  grid-template-columns: $lines;
+  grid-template-areas: "header header" "main sidebar";
+  a: [a b];
+  b: inspect([a]);
+  c: is-bracketed([a b]);
+  d: is-bracketed(a b);
+  e: length([a b]);
+}
+`
+	e := `div {
+  grid-template-columns: [full-start] 1fr [main-start];
+  grid-template-areas: "header header" "main sidebar";
+  a: [a b];
+  b: [a];
+  c: true;
+  d: false;
+  e: 2; }
+`
+	runParse(t, in, e)
+}
+
+func TestList_functions(t *testing.T) {
+	in := `$a: a b;
+$c: (c, d);
+div {
+  j1: join($a, $c);
+  j2: join($c, e, $separator: space);
+  j3: join(a, b, $bracketed: true);
+  a1: append($a, z);
+  a2: append($c, z);
+  a3: append(a, b, slash);
+  a4: inspect(append((a b), (c d)));
+  n1: nth($a, -1);
+  n2: nth($c, 1);
+  s1: set-nth($a, 2, q);
+  i1: index($c, d);
+  i2: index(1px 2px, 2px);
+  i3: index($c, x);
+  l1: list-separator($c);
+  l2: list-separator(a);
+  l3: list-separator(append(a, b, slash));
+}
+`
+	e := `div {
+  j1: a b c d;
+  j2: c d e;
+  j3: [a b];
+  a1: a b z;
+  a2: c, d, z;
+  a3: a / b;
+  a4: a b (c d);
+  n1: b;
+  n2: c;
+  s1: a q;
+  i1: 2;
+  i2: 2;
+  l1: comma;
+  l2: space;
+  l3: slash; }
+`
+	runParse(t, in, e)
+}
+
+func TestList_slashAssign(t *testing.T) {
+	in := `$s: a / b;
+$l: append(a, b, slash);
+$n: 4px / 2;
+div {
+  $t: c / d;
+  a: $s;
+  b: $l;
+  c: $t;
+  d: $n;
+}
+`
+	e := `div {
+  a: a/b;
+  b: a / b;
+  c: c/d;
+  d: 2px; }
+`
+	runParse(t, in, e)
+}
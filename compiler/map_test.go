@@ -0,0 +1,119 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/wellington/sass/token"
+)
+
+func TestMap_get(t *testing.T) {
+	in := `$map: (key: value, "q": 1px, nested: (a: b, c: d));
+$alias: $map;
+// the first key may be quoted or a number
+$quoted: ("a": 1, "b": 2);
+$numbers: (1: one, 2: two);
+div {
+  a: map-get($map, key);
+  b: map-get($map, q);
+  c: map-get(map-get($alias, nested), c);
+  d: map-get((x: y), x);
+  e: inspect(map-get($map, nested));
+  f: type-of($map);
+  g: map-get($map, missing);
+  h: type-of(map-get($map, missing));
+  i: map-get($quoted, "b") map-get($numbers, 2);
+}
+`
+	e := `div {
+  a: value;
+  b: 1px;
+  c: d;
+  d: y;
+  e: (a: b, c: d);
+  f: map;
+  h: null;
+  i: 2 two; }
+`
+	runParse(t, in, e)
+}
+
+func TestMap_each(t *testing.T) {
+	in := `$colors: (primary: blue, accent: red);
+div {
+  @each $pair in $colors {
+    pair: $pair;
+  }
+  @each $pair in (a: 1px, b: 2px) {
+    lit: $pair;
+  }
+}
+`
+	e := `div {
+  pair: primary blue;
+  pair: accent red;
+  lit: a 1px;
+  lit: b 2px; }
+`
+	runParse(t, in, e)
+}
+
+func TestMap_mixin(t *testing.T) {
+	in := `$theme: (fg: black, bg: white);
+@mixin colors($map) {
+  color: map-get($map, fg);
+  background: map-get($map, bg);
+}
+div {
+  @include colors($theme);
+}
+p {
+  @include colors((fg: red, bg: blue));
+}
+`
+	e := `div {
+  color: black;
+  background: white; }
+
+p {
+  color: red;
+  background: blue; }
+`
+	runParse(t, in, e)
+}
+
+func TestMap_functions(t *testing.T) {
+	in := `$a: (x: 1, y: 2);
+$b: map-merge($a, (y: 3, z: 4));
+div {
+  merge: inspect($b);
+  remove: inspect(map-remove($b, x));
+  keys: map-keys($b);
+  values: map-values($b);
+  has: map-has-key($b, z);
+  not: map-has-key($a, z);
+}
+`
+	e := `div {
+  merge: (x: 1, y: 3, z: 4);
+  remove: (y: 3, z: 4);
+  keys: x, y, z;
+  values: 1, 3, 4;
+  has: true;
+  not: false; }
+`
+	runParse(t, in, e)
+}
+
+func TestMap_invalid(t *testing.T) {
+	ctx := NewContext()
+	ctx.fset = token.NewFileSet()
+	_, err := ctx.runString("", `$map: (a: b);
+div { c: $map; }
+`)
+	if err == nil {
+		t.Fatal("expected error printing a map")
+	}
+	if e := "(a: b) isn't a valid CSS value."; err.Error() != e {
+		t.Errorf("got: %s wanted: %s", err, e)
+	}
+}
@@ -0,0 +1,64 @@
+package compiler
+
+import "testing"
+
+func TestMedia_expr(t *testing.T) {
+	in := `$bp: 600px;
+$m: "print";
+@media #{$m}, screen and (min-width: $bp + 1px) {
+  div { a: b; }
+}
+`
+	e := `@media print, screen and (min-width: 601px) {
+  div {
+    a: b; } }
+`
+	runParse(t, in, e)
+}
+
+func TestMedia_func(t *testing.T) {
+	in := `@media (min-width: calc(10px + 2em)), (bar: 3px hux(muz)) {
+  div { a: b; }
+}
+`
+	e := `@media (min-width: calc(10px + 2em)), (bar: 3px hux(muz)) {
+  div {
+    a: b; } }
+`
+	runParse(t, in, e)
+}
+
+func TestMedia_nested(t *testing.T) {
+	in := `@media screen {
+  div {
+    c: d;
+    @media (orientation: landscape) { a: b; }
+    @media print { e: f; }
+  }
+}
+`
+	e := `@media screen {
+  div {
+    c: d; } }
+
+@media screen and (orientation: landscape) {
+  div {
+    a: b; } }
+`
+	runParse(t, in, e)
+}
+
+func TestMedia_mixin(t *testing.T) {
+	in := `@mixin wide($w) {
+  @media (min-width: $w) { a: b; }
+}
+@media print and (orientation: landscape) {
+  div { @include wide(400px); }
+}
+`
+	e := `@media print and (orientation: landscape) and (min-width: 400px) {
+  div {
+    a: b; } }
+`
+	runParse(t, in, e)
+}
@@ -0,0 +1,161 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/wellington/sass/token"
+)
+
+func TestMixin_varargs(t *testing.T) {
+	in := `@mixin box-shadow($shadows...) {
+  box-shadow: $shadows;
+}
+@mixin pos($top, $right: 0, $bottom: $top) {
+  top: $top;
+  right: $right;
+  bottom: $bottom;
+}
+$shadows: 0 1px red, 0 2px blue;
+$map: (right: 5px, bottom: 6px);
+.a {
+  @include box-shadow(0 1px red, 0 2px blue);
+}
+.b {
+  @include box-shadow($shadows...);
+}
+.c {
+  @include pos(1px);
+}
+.d {
+  @include pos(1px, $map...);
+}
+`
+	e := `.a {
+  box-shadow: 0 1px red, 0 2px blue; }
+
+.b {
+  box-shadow: 0 1px red, 0 2px blue; }
+
+.c {
+  top: 1px;
+  right: 0;
+  bottom: 1px; }
+
+.d {
+  top: 1px;
+  right: 5px;
+  bottom: 6px; }
+`
+	runParse(t, in, e)
+}
+
+func TestMixin_keywords(t *testing.T) {
+	in := `@mixin colors($args...) {
+  color: map-get(keywords($args), fg);
+  count: length($args);
+}
+@mixin pos($top, $right: 0, $bottom: $top) {
+  top: $top;
+  right: $right;
+  bottom: $bottom;
+}
+@mixin wrap($args...) {
+  @include pos($args...);
+}
+.a {
+  @include colors(1, 2, $fg: red);
+}
+.b {
+  @include wrap(1px, $bottom: 2px);
+}
+`
+	e := `.a {
+  color: red;
+  count: 2; }
+
+.b {
+  top: 1px;
+  right: 0;
+  bottom: 2px; }
+`
+	runParse(t, in, e)
+}
+
+func TestMixin_emptyVarargs(t *testing.T) {
+	in := `@mixin m($args...) {
+  count: length($args);
+  fg: map-get(keywords($args), fg);
+}
+$l: ();
+.a {
+  @include m();
+}
+.b {
+  @include m($fg: red, $bg: blue);
+}
+.c {
+  l: length($l);
+}
+`
+	e := `.a {
+  count: 0; }
+
+.b {
+  count: 0;
+  fg: red; }
+
+.c {
+  l: 0; }
+`
+	runParse(t, in, e)
+}
+
+func TestMixin_keywordsValue(t *testing.T) {
+	for _, body := range []string{
+		"k: keywords($args);",
+		"$k: keywords($args); k: $k;",
+	} {
+		ctx := NewContext()
+		ctx.fset = token.NewFileSet()
+		_, err := ctx.runString("", "@mixin m($args...) { "+body+
+			" }\na { @include m($fg: red); }\n")
+		if err == nil {
+			t.Errorf("%s: expected error", body)
+			continue
+		}
+		if e := "(fg: red) isn't a valid CSS value."; err.Error() != e {
+			t.Errorf("got: %s wanted: %s", err, e)
+		}
+	}
+}
+
+func TestMixin_argErrors(t *testing.T) {
+	mixin := `@mixin pos($top, $right: 0) {
+  top: $top;
+  right: $right;
+}
+`
+	tests := []struct {
+		include string
+		err     string
+	}{
+		{"pos(1px, 2px, 3px)", "5:14: Only 2 argument(s) allowed, but 3 were passed."},
+		{"pos()", "5:14: Missing argument $top."},
+		{"pos($right: 1px)", "5:14: Missing argument $top."},
+		{"pos(1px, $top: 2px)", "5:14: Argument $top was passed both by position and by name."},
+		{"pos(1px, $left: 2px)", "5:14: No argument named $left."},
+		{"pos($nope...)", "5:14: Undefined variable: \"$nope\"."},
+	}
+	for _, test := range tests {
+		ctx := NewContext()
+		ctx.fset = token.NewFileSet()
+		_, err := ctx.runString("", mixin+"a { @include "+test.include+"; }\n")
+		if err == nil {
+			t.Errorf("%s: expected error", test.include)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("got: %s wanted: %s", err, test.err)
+		}
+	}
+}
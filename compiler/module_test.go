@@ -0,0 +1,154 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runModule writes files to a temporary directory and compiles main.scss
+func runModule(t *testing.T, files map[string]string) (string, error) {
+	dir, err := ioutil.TempDir("", "module")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, src := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := NewContext()
+	return ctx.runString(filepath.Join(dir, "main.scss"), nil)
+}
+
+func TestModule_use(t *testing.T) {
+	out, err := runModule(t, map[string]string{
+		"_lib.scss": `$color: red !default;
+$-gap: 1px;
+@function double($x) { @return $x * 2 + $-gap; }
+@mixin box($w) { width: $w; color: $color; }
+.lib { color: $color; }
+`,
+		"main.scss": `@use "lib" with ($color: blue);
+@use "lib" as again;
+@use "sass:map";
+$m: (a: 1px, b: 2px);
+div {
+  color: lib.$color;
+  width: lib.double(2px);
+  height: map.get($m, b);
+  @include again.box(3px);
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// lib is evaluated once
+	e := `.lib {
+  color: blue; }
+
+div {
+  color: blue;
+  width: 5px;
+  height: 2px;
+  width: 3px;
+  color: blue; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+}
+
+func TestModule_forward(t *testing.T) {
+	out, err := runModule(t, map[string]string{
+		"_base.scss": `$size: 10px;
+$gap: 2px;
+@function half($x) { @return $x / 2; }
+@mixin pad() { padding: $gap; }
+`,
+		"_theme.scss": `@forward "base" as base-* hide base-half;
+@use "base";
+@function twice($x) { @return base.half($x) * 4; }
+`,
+		"main.scss": `@use "theme";
+@use "sass:list" as *;
+@use "base" as b;
+div {
+  a: theme.$base-size;
+  b: theme.twice(4px);
+  c: nth(1px 2px 3px, 2);
+  d: b.half(8px);
+  @include theme.base-pad;
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `div {
+  a: 10px;
+  b: 8px;
+  c: 2px;
+  d: 4px;
+  padding: 2px; }
+`
+	if e != out {
+		t.Fatalf("got:\n%s\nwanted:\n%s", out, e)
+	}
+}
+
+func TestModule_config(t *testing.T) {
+	_, err := runModule(t, map[string]string{
+		"_lib.scss": `$a: 1 !default;
+$b: 2;
+`,
+		"main.scss": `@use "lib" with ($b: 3);
+`,
+	})
+	if err == nil || !strings.Contains(err.Error(), "$b was not declared with !default") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = runModule(t, map[string]string{
+		"_a.scss":   `@use "b";`,
+		"_b.scss":   `@use "a";`,
+		"main.scss": `@use "a";`,
+	})
+	if err == nil || !strings.Contains(err.Error(), "module loop") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestModule_members(t *testing.T) {
+	lib := `$c: red;
+$-p: 1px;
+`
+	tests := []struct {
+		main string
+		err  string
+	}{
+		{"div {\n  a: lib.$nope;\n}\n", `main.scss:3:6: Undefined variable: "lib.$nope".`},
+		{"div {\n  a: lib.$-p;\n}\n", "main.scss:3:6: Private members can't be accessed from outside their modules."},
+		{"div {\n  a: nope.$c;\n}\n", `main.scss:3:6: There is no module with the namespace "nope".`},
+		{"$x: lib.$nope;\n", `main.scss:2:5: Undefined variable: "lib.$nope".`},
+		{"@mixin m() { b: lib.$nope; }\ndiv {\n  @include m;\n}\n", `main.scss:2:17: Undefined variable: "lib.$nope".`},
+		{"div {\n  a: lib.nope(1);\n}\n", `main.scss:3:6: Undefined function: "lib.nope".`},
+		{"@use \"sass:math\";\ndiv {\n  a: math.nope(1);\n}\n", `main.scss:4:6: Undefined function: "math.nope".`},
+		{"div {\n  a: nope.fn(1);\n}\n", `main.scss:3:6: There is no module with the namespace "nope".`},
+	}
+	for _, tt := range tests {
+		_, err := runModule(t, map[string]string{
+			"_lib.scss": lib,
+			"main.scss": "@use \"lib\";\n" + tt.main,
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: got: %v wanted: %s", tt.main, err, tt.err)
+		}
+	}
+}
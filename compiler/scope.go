@@ -13,8 +13,8 @@ type Scope interface {
 	RuleAdd(*ast.RuleSpec)
 	RuleLen() int
 
-	RegisterMixin(string, int, *MixFn)
-	Mixin(string, int) (*MixFn, error)
+	RegisterMixin(string, *MixFn)
+	Mixin(string) (*MixFn, error)
 }
 
 var (
@@ -27,9 +27,9 @@ type emptyTyp struct{}
 // 	return nil
 // }
 
-func (*emptyTyp) RegisterMixin(_ string, _ int, _ *MixFn) {}
+func (*emptyTyp) RegisterMixin(_ string, _ *MixFn) {}
 
-func (*emptyTyp) Mixin(_ string, _ int) (*MixFn, error) {
+func (*emptyTyp) Mixin(_ string) (*MixFn, error) {
 	return nil, ErrMixinNotFound
 }
 
@@ -0,0 +1,79 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/wellington/sass/token"
+)
+
+func TestWhile(t *testing.T) {
+	in := `div {
+  $i: 1;
+  @while $i <= 3 {
+    i: $i;
+    $i: $i + 1;
+  }
+}
+`
+	e := `div {
+  i: 1;
+  i: 2;
+  i: 3; }
+`
+	runParse(t, in, e)
+}
+
+func TestWhile_selectors(t *testing.T) {
+	in := `$i: 6;
+@while $i > 0 {
+  .item-#{$i} { width: 2em * $i; }
+  $i: $i - 2;
+}
+a { b: $i; }
+`
+	e := `.item-6 {
+  width: 12em; }
+
+.item-4 {
+  width: 8em; }
+
+.item-2 {
+  width: 4em; }
+
+a {
+  b: 0; }
+`
+	runParse(t, in, e)
+}
+
+func TestWhile_false(t *testing.T) {
+	in := `div {
+  $i: 0;
+  @while $i > 0 {
+    i: $i;
+  }
+  j: $i;
+}
+`
+	e := `div {
+  j: 0; }
+`
+	runParse(t, in, e)
+}
+
+func TestWhile_limit(t *testing.T) {
+	ctx := NewContext()
+	ctx.fset = token.NewFileSet()
+	ctx.SetMaxIterations(10)
+	_, err := ctx.runString("", `$i: 1;
+div {
+  @while $i > 0 { i: $i; }
+}
+`)
+	if err == nil {
+		t.Fatal("expected error for endless loop")
+	}
+	if e := "3:3: @while exceeded 10 iterations"; err.Error() != e {
+		t.Errorf("got: %s wanted: %s", err, e)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	_ "github.com/wellington/sass/builtin/colors"
 	_ "github.com/wellington/sass/builtin/introspect"
 	_ "github.com/wellington/sass/builtin/list"
+	_ "github.com/wellington/sass/builtin/maps"
 	_ "github.com/wellington/sass/builtin/strops"
 	_ "github.com/wellington/sass/builtin/url"
 )
@@ -115,19 +116,128 @@ func evaluateCall(p *parser, scope *ast.Scope, expr *ast.CallExpr) (ast.Expr, er
 	ident := expr.Fun.(*ast.Ident)
 	name := ident.Name
 
+	if isIf(expr) {
+		return p.callIf(expr)
+	}
+	// members of built-in modules are global builtins
+	if fn, ok := p.builtinMember(name); ok {
+		name = fn
+	}
 	// First check builtins
 	if fn, ok := builtins[name]; ok {
-		return callBuiltin(name, fn, expr)
+		call, err := p.spreadCall(expr)
+		if err != nil {
+			return nil, err
+		}
+		return callBuiltin(name, fn, call)
 	}
 	return p.callInline(scope, expr)
 }
 
+// ifParams are the parameters of if()
+var ifParams = []string{"$condition", "$if-true", "$if-false"}
+
+// isIf reports whether call is if(). if() can not be registered as
+// a builtin, the arguments of builtins are evaluated before the call.
+func isIf(call *ast.CallExpr) bool {
+	ident, ok := call.Fun.(*ast.Ident)
+	return ok && ident.Name == "if"
+}
+
+// callIf evaluates if($condition, $if-true, $if-false). Only the
+// branch selected by $condition is evaluated.
+func (p *parser) callIf(call *ast.CallExpr) (ast.Expr, error) {
+	args := make([]ast.Expr, len(ifParams))
+	var pos int
+	for _, arg := range call.Args {
+		kv, ok := arg.(*ast.KeyValueExpr)
+		if !ok {
+			if pos >= len(args) {
+				return nil, fmt.Errorf("if() takes %d arguments but %d were passed",
+					len(ifParams), len(call.Args))
+			}
+			args[pos] = arg
+			pos++
+			continue
+		}
+		name := kv.Key.(*ast.Ident).Name
+		i := 0
+		for i < len(ifParams) && ifParams[i] != name {
+			i++
+		}
+		if i == len(ifParams) {
+			return nil, fmt.Errorf("if() has no argument named %s", name)
+		}
+		args[i] = kv.Value
+	}
+	for i := range args {
+		if args[i] == nil {
+			return nil, fmt.Errorf("if() is missing argument %s", ifParams[i])
+		}
+	}
+
+	ok, err := p.evalCond(ast.ExprCopy(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	x := args[2]
+	if ok {
+		x = args[1]
+	}
+	x, err = p.evalExpr(ast.ExprCopy(x))
+	if err != nil {
+		return nil, err
+	}
+	return funcValue(x), nil
+}
+
+// spreadCall expands arguments passed with ... for a builtin
+func (p *parser) spreadCall(call *ast.CallExpr) (*ast.CallExpr, error) {
+	var spread bool
+	for _, arg := range call.Args {
+		if _, ok := spreadIdent(arg); ok {
+			spread = true
+		}
+	}
+	if !spread {
+		return call, nil
+	}
+	args, kws, err := p.callArgs(call.Args)
+	if err != nil {
+		return nil, err
+	}
+	c := *call
+	c.Args = args
+	for _, kw := range kws {
+		c.Args = append(c.Args, kw)
+	}
+	return &c, nil
+}
+
 // callInline looks for the function within Sass itself
 func (p *parser) callInline(scope *ast.Scope, call *ast.CallExpr) (ast.Expr, error) {
 
 	return p.resolveFuncDecl(scope, call)
 }
 
+// builtinArg prepares an incoming argument for a builtin, lists and
+// maps are passed as is while other values are resolved
+func builtinArg(arg ast.Expr) (ast.Expr, error) {
+	switch v := arg.(type) {
+	case *ast.ListLit, *ast.MapLit:
+		return v, nil
+	case *ast.CallExpr:
+		return v.Resolved, nil
+	case *ast.Ident:
+		if v.Obj != nil {
+			ass := v.Obj.Decl.(*ast.AssignStmt)
+			return ass.Rhs[0], nil
+		}
+		return v, nil
+	}
+	return calc.Resolve(arg, true)
+}
+
 func callBuiltin(name string, fn call, expr *ast.CallExpr) (ast.Expr, error) {
 
 	// Walk through the function
@@ -160,28 +270,21 @@ func callBuiltin(name string, fn call, expr *ast.CallExpr) (ast.Expr, error) {
 		if argpos < i {
 			argpos = i
 		}
-		switch v := arg.(type) {
-		case *ast.KeyValueExpr:
-			pos := fn.Pos(v.Key.(*ast.Ident))
-			callargs[pos] = v.Value.(*ast.BasicLit)
-		case *ast.ListLit:
-			callargs[argpos] = v
-		case *ast.Ident:
-			if v.Obj != nil {
-				ass := v.Obj.Decl.(*ast.AssignStmt)
-				callargs[argpos] = ass.Rhs[0]
-			} else {
-				callargs[argpos] = v
-			}
-
-		default:
-			lit, err := calc.Resolve(v, true)
-			if err == nil {
-				callargs[argpos] = lit
-			} else {
-				return nil, err
+		pos := argpos
+		if kv, ok := arg.(*ast.KeyValueExpr); ok {
+			key := kv.Key.(*ast.Ident)
+			pos = fn.Pos(key)
+			if pos < 0 {
+				return nil, fmt.Errorf("%s has no argument named %s",
+					name, key.Name)
 			}
+			arg = kv.Value
+		}
+		x, err := builtinArg(arg)
+		if err != nil {
+			return nil, err
 		}
+		callargs[pos] = x
 	}
 	if fn.ch != nil {
 		lits := make([]*ast.BasicLit, len(callargs))
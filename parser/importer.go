@@ -0,0 +1,409 @@
+package parser
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// An Importer finds and loads the stylesheets of @import, @use and
+// @forward.
+type Importer interface {
+	// Canonicalize returns the canonical name of the stylesheet
+	// imported as path by the stylesheet named from. It returns ""
+	// if the stylesheet can not be found.
+	Canonicalize(path, from string) (string, error)
+	// Load returns the contents of the stylesheet named by a
+	// canonical name.
+	Load(name string) ([]byte, error)
+}
+
+// A GlobImporter is an Importer expanding glob imports ie.
+// @import "components/*". The importers of this package implement it.
+type GlobImporter interface {
+	Importer
+	// Glob returns the canonical names of the .scss and .sass files
+	// matching pattern, in sorted order. * matches any part of a name
+	// and ** any number of directories. Partials are matched by the
+	// name they are imported with. match is pattern joined with the
+	// directory the files were found in.
+	Glob(pattern, from string) (names []string, match string, err error)
+}
+
+// FileImporter imports files relative to the importing file, then
+// relative to each of IncludePaths in order.
+//
+// Paths without an extension match .scss, .sass and .css files, their
+// partials ie. _name.scss, and the index files of directories ie.
+// name/_index.scss. A path matching more than one file is an error.
+type FileImporter struct {
+	IncludePaths []string
+
+	// Roots confine imports to the files inside of these directories,
+	// symbolic links are followed before checking. Importing other
+	// files is an error. Empty allows every file.
+	Roots []string
+}
+
+// NewFileImporter returns a FileImporter searching includePaths
+func NewFileImporter(includePaths ...string) *FileImporter {
+	return &FileImporter{IncludePaths: includePaths}
+}
+
+// Canonicalize returns the absolute path of the file imported as path
+func (imp *FileImporter) Canonicalize(path, from string) (string, error) {
+	dirs := append([]string{filepath.Dir(from)}, imp.IncludePaths...)
+	if filepath.IsAbs(path) {
+		dirs = []string{""}
+	}
+	for i := range dirs {
+		dirs[i] = filepath.ToSlash(dirs[i])
+	}
+	name, err := searchFile(filepath.ToSlash(path), dirs, func(name string) bool {
+		fi, err := os.Stat(filepath.FromSlash(name))
+		return err == nil && !fi.IsDir()
+	})
+	if name == "" || err != nil {
+		return "", err
+	}
+	name, err = filepath.Abs(filepath.FromSlash(name))
+	if err != nil {
+		return "", err
+	}
+	if len(imp.Roots) > 0 && !imp.inRoots(name) {
+		return "", fmt.Errorf("%s is outside of the allowed roots", path)
+	}
+	return name, nil
+}
+
+// inRoots reports whether the file name is inside of one of Roots
+func (imp *FileImporter) inRoots(name string) bool {
+	name, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return false
+	}
+	return imp.inRootsResolved(name)
+}
+
+// inRootsResolved is inRoots of a name without symbolic links
+func (imp *FileImporter) inRootsResolved(name string) bool {
+	for _, root := range imp.Roots {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		root, err = filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, name)
+		if err == nil && rel != ".." &&
+			!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Glob returns the absolute paths of the files matching pattern
+func (imp *FileImporter) Glob(pattern, from string) ([]string, string, error) {
+	dirs := append([]string{filepath.Dir(from)}, imp.IncludePaths...)
+	if filepath.IsAbs(pattern) {
+		dirs = []string{""}
+	}
+	for i := range dirs {
+		dirs[i] = filepath.ToSlash(dirs[i])
+	}
+	var outside bool
+	names, match := globFiles(filepath.ToSlash(pattern), dirs, func(root string) []string {
+		// directories outside of the roots are not walked
+		if len(imp.Roots) > 0 {
+			abs, err := filepath.Abs(filepath.FromSlash(root))
+			if err != nil || !imp.inRoots(abs) {
+				outside = true
+				return nil
+			}
+		}
+		var names []string
+		filepath.Walk(filepath.FromSlash(root), func(name string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() {
+				names = append(names, filepath.ToSlash(name))
+			}
+			return nil
+		})
+		return names
+	})
+	if len(names) == 0 && outside {
+		return nil, "", fmt.Errorf("%s is outside of the allowed roots", pattern)
+	}
+	for i, name := range names {
+		abs, err := filepath.Abs(filepath.FromSlash(name))
+		if err != nil {
+			return nil, "", err
+		}
+		if len(imp.Roots) > 0 && !imp.inRoots(abs) {
+			return nil, "", fmt.Errorf("%s is outside of the allowed roots", name)
+		}
+		names[i] = abs
+	}
+	if match != "" {
+		var err error
+		if match, err = filepath.Abs(filepath.FromSlash(match)); err != nil {
+			return nil, "", err
+		}
+	}
+	return names, match, nil
+}
+
+// Load reads the file name. With Roots, the file its symbolic links
+// point to is checked again and read, so links changed after
+// Canonicalize can't escape the roots.
+func (imp *FileImporter) Load(name string) ([]byte, error) {
+	if len(imp.Roots) == 0 {
+		return ioutil.ReadFile(name)
+	}
+	resolved, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return nil, err
+	}
+	if !imp.inRootsResolved(resolved) {
+		return nil, fmt.Errorf("%s is outside of the allowed roots", name)
+	}
+	return ioutil.ReadFile(resolved)
+}
+
+// FSImporter imports files of a virtual file system like embed.FS or
+// os.DirFS. Files are found like FileImporter finds them, names are
+// slash separated paths relative to the root of the file system.
+type FSImporter struct {
+	FS           fs.FS
+	IncludePaths []string
+}
+
+// NewFSImporter returns a FSImporter reading fsys and searching
+// includePaths
+func NewFSImporter(fsys fs.FS, includePaths ...string) *FSImporter {
+	return &FSImporter{FS: fsys, IncludePaths: includePaths}
+}
+
+// Canonicalize returns the name of the file imported as p in the file
+// system
+func (imp *FSImporter) Canonicalize(p, from string) (string, error) {
+	p, dirs := importDirs(p, from, imp.IncludePaths)
+	return searchFile(p, dirs, func(name string) bool {
+		fi, err := fs.Stat(imp.FS, name)
+		return err == nil && !fi.IsDir()
+	})
+}
+
+// Glob returns the names of the files of the file system matching
+// pattern
+func (imp *FSImporter) Glob(pattern, from string) ([]string, string, error) {
+	pattern, dirs := importDirs(pattern, from, imp.IncludePaths)
+	names, match := globFiles(pattern, dirs, func(root string) []string {
+		var names []string
+		fs.WalkDir(imp.FS, root, func(name string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				names = append(names, name)
+			}
+			return nil
+		})
+		return names
+	})
+	return names, match, nil
+}
+
+// Load reads the file name of the file system
+func (imp *FSImporter) Load(name string) ([]byte, error) {
+	return fs.ReadFile(imp.FS, name)
+}
+
+// MapImporter imports sources held in memory, the keys of Files are
+// slash separated paths. Files are found like FileImporter finds them.
+type MapImporter struct {
+	Files        map[string][]byte
+	IncludePaths []string
+}
+
+// NewMapImporter returns a MapImporter of files searching includePaths
+func NewMapImporter(files map[string][]byte, includePaths ...string) *MapImporter {
+	return &MapImporter{Files: files, IncludePaths: includePaths}
+}
+
+// Canonicalize returns the key of the source imported as p
+func (imp *MapImporter) Canonicalize(p, from string) (string, error) {
+	p, dirs := importDirs(p, from, imp.IncludePaths)
+	return searchFile(p, dirs, func(name string) bool {
+		_, ok := imp.Files[name]
+		return ok
+	})
+}
+
+// Glob returns the keys of the sources matching pattern
+func (imp *MapImporter) Glob(pattern, from string) ([]string, string, error) {
+	pattern, dirs := importDirs(pattern, from, imp.IncludePaths)
+	names, match := globFiles(pattern, dirs, func(root string) []string {
+		var names []string
+		for name := range imp.Files {
+			if root == "." || strings.HasPrefix(name, root+"/") {
+				names = append(names, name)
+			}
+		}
+		return names
+	})
+	return names, match, nil
+}
+
+// Load returns the source name
+func (imp *MapImporter) Load(name string) ([]byte, error) {
+	src, ok := imp.Files[name]
+	if !ok {
+		return nil, fmt.Errorf("open %s: file does not exist", name)
+	}
+	return src, nil
+}
+
+// importDirs lists the directories searched for p imported by from in
+// a virtual file system, its names have no leading slash
+func importDirs(p, from string, includePaths []string) (string, []string) {
+	if path.IsAbs(p) {
+		return strings.TrimPrefix(p, "/"), []string{""}
+	}
+	return p, append([]string{path.Dir(from)}, includePaths...)
+}
+
+// searchFile finds the file imported as p in the first of dirs holding
+// it, exists reports whether the slash separated name is a file
+func searchFile(p string, dirs []string, exists func(string) bool) (string, error) {
+	for _, dir := range dirs {
+		name, err := findFile(path.Join(dir, p), exists)
+		if name != "" || err != nil {
+			return name, err
+		}
+	}
+	return "", nil
+}
+
+// findFile finds the stylesheet name refers to
+func findFile(name string, exists func(string) bool) (string, error) {
+	switch path.Ext(name) {
+	case ".scss", ".sass", ".css":
+		return onlyFile(exists, name, partial(name))
+	}
+	if found, err := onlyFile(exists, name+".scss", name+".sass",
+		partial(name+".scss"), partial(name+".sass")); found != "" || err != nil {
+		return found, err
+	}
+	if found, err := onlyFile(exists, name+".css", partial(name+".css")); found != "" || err != nil {
+		return found, err
+	}
+	index := path.Join(name, "index")
+	if found, err := onlyFile(exists, index+".scss", index+".sass",
+		partial(index+".scss"), partial(index+".sass")); found != "" || err != nil {
+		return found, err
+	}
+	return onlyFile(exists, index+".css", partial(index+".css"))
+}
+
+// partial returns the name of the partial of name ie. dir/_name.scss
+func partial(name string) string {
+	dir, base := path.Split(name)
+	return dir + "_" + base
+}
+
+// onlyFile returns the one of names that exists, several existing
+// makes the import ambiguous
+func onlyFile(exists func(string) bool, names ...string) (string, error) {
+	var found []string
+	for _, name := range names {
+		if exists(name) {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("it's not clear which file to import, found: %s",
+		strings.Join(found, ", "))
+}
+
+// isGlob reports whether the import path is a glob pattern
+func isGlob(path string) bool {
+	return strings.Contains(path, "*")
+}
+
+// globFiles returns the stylesheets matching pattern in the first of
+// dirs having any and pattern joined with that directory. list returns
+// the files below a directory.
+func globFiles(pattern string, dirs []string, list func(root string) []string) ([]string, string) {
+	for _, dir := range dirs {
+		// names are matched with the pattern joined with dir, so
+		// patterns leaving dir ie. ../shared/* match
+		full := path.Join(dir, pattern)
+		segs := strings.Split(full, "/")
+		// only the directories below the part without wildcards are
+		// listed
+		root := "."
+		for i, seg := range segs {
+			if isGlob(seg) {
+				if i > 0 {
+					root = strings.Join(segs[:i], "/")
+				}
+				break
+			}
+		}
+		if root == "" {
+			root = "/"
+		}
+		var found []string
+		for _, name := range list(root) {
+			ext := path.Ext(name)
+			if ext != ".scss" && ext != ".sass" {
+				continue
+			}
+			// partials match by the name they are imported with
+			sub, base := path.Split(strings.TrimSuffix(name, ext))
+			short := sub + strings.TrimPrefix(base, "_")
+			if matchGlob(segs, strings.Split(name, "/")) ||
+				matchGlob(segs, strings.Split(short, "/")) {
+				found = append(found, name)
+			}
+		}
+		if len(found) > 0 {
+			sort.Strings(found)
+			return found, full
+		}
+	}
+	return nil, ""
+}
+
+// matchGlob reports whether the path segments segs match the pattern
+// segments pat, ** matches any number of segments
+func matchGlob(pat, segs []string) bool {
+	if len(pat) == 0 {
+		return len(segs) == 0
+	}
+	if pat[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchGlob(pat[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pat[0], segs[0]); !ok {
+		return false
+	}
+	return matchGlob(pat[1:], segs[1:])
+}
@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileImporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"src/main.scss",
+		"src/_local.scss",
+		"shared/_local.scss",
+		"shared/_colors.scss",
+		"shared/reset.css",
+		"shared/grid/_index.scss",
+		"vendor/_colors.scss",
+		"vendor/both.scss",
+		"vendor/_both.scss",
+		"vendor/mixed.scss",
+		"vendor/mixed.sass",
+	}
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	imp := NewFileImporter(filepath.Join(dir, "shared"), filepath.Join(dir, "vendor"))
+	from := filepath.Join(dir, "src/main.scss")
+	table := []struct {
+		path string
+		name string
+	}{
+		// the importing file comes before include paths
+		{"local", "src/_local.scss"},
+		// include paths are searched in order
+		{"colors", "shared/_colors.scss"},
+		{"reset", "shared/reset.css"},
+		{"grid", "shared/grid/_index.scss"},
+		{"missing", ""},
+	}
+	for _, tt := range table {
+		name, err := imp.Canonicalize(tt.path, from)
+		if err != nil {
+			t.Errorf("%s: %s", tt.path, err)
+			continue
+		}
+		e := ""
+		if tt.name != "" {
+			e = filepath.Join(dir, tt.name)
+		}
+		if name != e {
+			t.Errorf("%s: got %q wanted %q", tt.path, name, e)
+		}
+	}
+
+	for _, path := range []string{"both", "mixed"} {
+		_, err := imp.Canonicalize(path, from)
+		if err == nil || !strings.Contains(err.Error(), "not clear which file") {
+			t.Errorf("%s: expected ambiguous import got: %v", path, err)
+		}
+	}
+}
+
+func TestFSImporter(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/main.scss":          {},
+		"src/_local.scss":        {},
+		"shared/_colors.scss":    {},
+		"shared/reset.css":       {},
+		"shared/grid/index.sass": {},
+		"shared/both.scss":       {},
+		"shared/_both.scss":      {},
+	}
+	files := make(map[string][]byte)
+	for name := range fsys {
+		files[name] = nil
+	}
+	importers := []Importer{
+		NewFSImporter(fsys, "shared"),
+		NewMapImporter(files, "shared"),
+	}
+	table := []struct {
+		path string
+		name string
+	}{
+		{"local", "src/_local.scss"},
+		{"colors", "shared/_colors.scss"},
+		{"reset", "shared/reset.css"},
+		{"grid", "shared/grid/index.sass"},
+		{"/shared/colors", "shared/_colors.scss"},
+		{"../shared/colors.scss", "shared/_colors.scss"},
+		{"missing", ""},
+	}
+	for _, imp := range importers {
+		for _, tt := range table {
+			name, err := imp.Canonicalize(tt.path, "src/main.scss")
+			if err != nil {
+				t.Errorf("%T %s: %s", imp, tt.path, err)
+				continue
+			}
+			if name != tt.name {
+				t.Errorf("%T %s: got %q wanted %q", imp, tt.path, name, tt.name)
+			}
+		}
+		_, err := imp.Canonicalize("both", "src/main.scss")
+		if err == nil || !strings.Contains(err.Error(), "not clear which file") {
+			t.Errorf("%T: expected ambiguous import got: %v", imp, err)
+		}
+	}
+}
+
+func TestFileImporter_roots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"root/main.scss", "root/_ok.scss", "secret/_key.scss"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.Symlink(filepath.Join(dir, "secret/_key.scss"),
+		filepath.Join(dir, "root/_link.scss"))
+	if err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+
+	imp := &FileImporter{Roots: []string{filepath.Join(dir, "root")}}
+	from := filepath.Join(dir, "root/main.scss")
+	if _, err := imp.Canonicalize("ok", from); err != nil {
+		t.Fatal(err)
+	}
+	escapes := []string{
+		"../secret/key",
+		filepath.Join(dir, "secret/key"),
+		"link",
+	}
+	for _, path := range escapes {
+		_, err := imp.Canonicalize(path, from)
+		if err == nil || !strings.Contains(err.Error(), "outside of the allowed roots") {
+			t.Errorf("%s: expected error got: %v", path, err)
+		}
+	}
+
+	// globs leaving the roots are not walked
+	for _, pattern := range []string{"/**/*", "../**/*", filepath.ToSlash(dir) + "/*/*"} {
+		_, _, err := imp.Glob(pattern, from)
+		if err == nil || !strings.Contains(err.Error(), "outside of the allowed roots") {
+			t.Errorf("%s: expected error got: %v", pattern, err)
+		}
+	}
+
+	// a file swapped for a link after Canonicalize is not read
+	name, err := imp.Canonicalize("ok", from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret/_key.scss"), name); err != nil {
+		t.Fatal(err)
+	}
+	_, err = imp.Load(name)
+	if err == nil || !strings.Contains(err.Error(), "outside of the allowed roots") {
+		t.Errorf("expected error got: %v", err)
+	}
+}
+
+func TestImporter_glob(t *testing.T) {
+	files := map[string][]byte{
+		"src/main.scss":                    nil,
+		"src/components/_button.scss":      nil,
+		"src/components/alert.sass":        nil,
+		"src/components/notes.txt":         nil,
+		"src/components/forms/_input.scss": nil,
+		"src/components/forms/select.scss": nil,
+		"shared/mixins/_grid.scss":         nil,
+	}
+	fsys := fstest.MapFS{}
+	for name := range files {
+		fsys[name] = &fstest.MapFile{}
+	}
+	importers := []GlobImporter{
+		NewFSImporter(fsys, "shared"),
+		NewMapImporter(files, "shared"),
+	}
+	table := []struct {
+		pattern string
+		names   []string
+		match   string
+	}{
+		{"components/*", []string{
+			"src/components/_button.scss",
+			"src/components/alert.sass",
+		}, "src/components/*"},
+		{"components/**/*", []string{
+			"src/components/_button.scss",
+			"src/components/alert.sass",
+			"src/components/forms/_input.scss",
+			"src/components/forms/select.scss",
+		}, "src/components/**/*"},
+		{"components/**/_*.scss", []string{
+			"src/components/_button.scss",
+			"src/components/forms/_input.scss",
+		}, "src/components/**/_*.scss"},
+		{"components/forms/in*", []string{
+			"src/components/forms/_input.scss",
+		}, "src/components/forms/in*"},
+		// include paths are searched when the importing directory has
+		// no match
+		{"mixins/*", []string{"shared/mixins/_grid.scss"}, "shared/mixins/*"},
+		{"../shared/**/*", []string{"shared/mixins/_grid.scss"}, "shared/**/*"},
+		{"/shared/mixins/*", []string{"shared/mixins/_grid.scss"}, "shared/mixins/*"},
+		{"missing/*", nil, ""},
+	}
+	for _, imp := range importers {
+		for _, tt := range table {
+			names, match, err := imp.Glob(tt.pattern, "src/main.scss")
+			if err != nil {
+				t.Errorf("%T %s: %s", imp, tt.pattern, err)
+				continue
+			}
+			if strings.Join(names, " ") != strings.Join(tt.names, " ") {
+				t.Errorf("%T %s: got %q wanted %q", imp, tt.pattern, names, tt.names)
+			}
+			if match != tt.match {
+				t.Errorf("%T %s: got match %q wanted %q", imp, tt.pattern, match, tt.match)
+			}
+		}
+	}
+}
@@ -8,6 +8,7 @@ package parser
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -60,6 +61,8 @@ const (
 	Trace                                          // print a trace of parsed productions
 	DeclarationErrors                              // report declaration errors
 	SpuriousErrors                                 // same as AllErrors, for backward-compatibility
+	Indented                                       // parse the indented syntax of .sass files
+	ImportOnce                                     // skip files imported earlier in the compilation
 	AllErrors         = SpuriousErrors             // report all errors (not just the first 10 on different lines)
 )
 
@@ -83,8 +86,60 @@ const (
 // are returned via a scanner.ErrorList which is sorted by file position.
 //
 func ParseFile(fset *token.FileSet, filename string, src interface{}, mode Mode) (f *ast.File, err error) {
-	// get source
-	text, err := readSource(filename, src)
+	return ParseFileOptions(fset, filename, src, mode, nil)
+}
+
+// DefaultMaxIterations is the number of times a @while loop may run
+// when Options does not specify a limit.
+const DefaultMaxIterations = 10000
+
+// Options control how the parser evaluates the stylesheet. A nil
+// *Options uses the defaults.
+type Options struct {
+	// MaxIterations stops @while loops running forever, a loop
+	// running more than MaxIterations times is reported as an error.
+	// Zero means DefaultMaxIterations.
+	MaxIterations int
+
+	// Importer finds and loads the files of @import, @use and
+	// @forward. If nil, files are imported relative to the importing
+	// file by a FileImporter without include paths. ParseFileOptions
+	// loads filename with Importer when src is nil.
+	Importer Importer
+}
+
+// A Diagnostic is a message of @debug or @warn
+type Diagnostic struct {
+	Tok token.Token    // token.DEBUG or token.WARN
+	Pos token.Position // position of the directive
+	Msg string
+}
+
+func (d Diagnostic) String() string {
+	kind := "DEBUG"
+	if d.Tok == token.WARN {
+		kind = "WARNING"
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Pos, kind, d.Msg)
+}
+
+// ParseFileOptions is like ParseFile, but evaluation is configured
+// by opts.
+func ParseFileOptions(fset *token.FileSet, filename string, src interface{}, mode Mode, opts *Options) (f *ast.File, err error) {
+	// files read from disk are named like FileImporter names their
+	// imports, so imports of the file itself are recognized
+	if _, ok := optsImporter(opts).(*FileImporter); ok && src == nil {
+		if filename, err = filepath.Abs(filename); err != nil {
+			return nil, err
+		}
+	}
+	// get source, files of a custom importer are loaded by it
+	var text []byte
+	if src == nil && opts != nil && opts.Importer != nil {
+		text, err = opts.Importer.Load(filename)
+	} else {
+		text, err = readSource(filename, src)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -115,12 +170,25 @@ func ParseFile(fset *token.FileSet, filename string, src interface{}, mode Mode)
 
 	// parse source
 	p.init(fset, filename, text, mode)
+	p.maxIterations = DefaultMaxIterations
+	if opts != nil && opts.MaxIterations > 0 {
+		p.maxIterations = opts.MaxIterations
+	}
+	p.importer = optsImporter(opts)
 	p.next()
 	f = p.parseFile()
 
 	return
 }
 
+// optsImporter returns the importer of opts, a FileImporter by default
+func optsImporter(opts *Options) Importer {
+	if opts != nil && opts.Importer != nil {
+		return opts.Importer
+	}
+	return NewFileImporter()
+}
+
 // ParseDir calls ParseFile for all files with names ending in ".go" in the
 // directory specified by path and returns a map of package name -> package
 // AST with all the packages found.
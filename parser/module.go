@@ -0,0 +1,440 @@
+package parser
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/wellington/sass/ast"
+	"github.com/wellington/sass/token"
+)
+
+// module is a file loaded by @use or @forward. Modules have their own
+// global scope and are evaluated once, later loads share the members
+// of the first.
+type module struct {
+	path     string
+	exports  *ast.Scope   // members visible to users; nil while parsing
+	forwards *ast.Scope   // members forwarded by @forward
+	globals  []*ast.Scope // members of modules used with as *
+}
+
+func newModule(path string) *module {
+	return &module{
+		path:     path,
+		forwards: ast.NewScope(nil),
+	}
+}
+
+// builtinModules lists the members of the built-in modules loaded with
+// @use "sass:<name>", each maps to a global builtin function
+var builtinModules = map[string]map[string]string{
+	"color": {
+		"red":    "red",
+		"green":  "green",
+		"blue":   "blue",
+		"mix":    "mix",
+		"invert": "invert",
+	},
+	"list": {
+		"length":       "length",
+		"nth":          "nth",
+		"set-nth":      "set-nth",
+		"join":         "join",
+		"append":       "append",
+		"index":        "index",
+		"separator":    "list-separator",
+		"is-bracketed": "is-bracketed",
+	},
+	"map": {
+		"get":     "map-get",
+		"has-key": "map-has-key",
+		"keys":    "map-keys",
+		"merge":   "map-merge",
+		"remove":  "map-remove",
+		"values":  "map-values",
+	},
+	"math": {
+		"unit": "unit",
+	},
+	"meta": {
+		"inspect":  "inspect",
+		"keywords": "keywords",
+		"type-of":  "type-of",
+	},
+	"string": {
+		"unquote": "unquote",
+	},
+}
+
+// builtinModule returns the module sass:name
+func builtinModule(name string) (*module, bool) {
+	members, ok := builtinModules[name]
+	if !ok {
+		return nil, false
+	}
+	m := newModule("sass:" + name)
+	m.exports = ast.NewScope(nil)
+	for member, fn := range members {
+		obj := ast.NewObj(ast.Fun, member)
+		obj.Data = fn
+		m.exports.Objects[member] = obj
+	}
+	return m, true
+}
+
+// @use "path" as ns with ($var: value);
+func (p *parser) parseUseDecl() *ast.GenDecl {
+	if p.trace {
+		defer un(trace(p, "UseDecl"))
+	}
+
+	pos := p.expect(token.USE)
+	spec := &ast.UseSpec{Use: pos, Path: p.parseModulePath()}
+	spec.Name = namespace(spec.Path.Value)
+	if p.tok == token.STRING && p.lit == "as" {
+		p.next()
+		if p.tok != token.STRING {
+			p.errorExpected(p.pos, "namespace")
+		}
+		spec.Name = p.lit
+		p.next()
+	}
+	if p.tok == token.STRING && p.lit == "with" {
+		p.next()
+		_, list, _ := p.parseArgs()
+		args, kws, err := p.callArgs(list)
+		if err != nil {
+			p.error(pos, err.Error())
+		}
+		if len(args) > 0 {
+			p.error(args[0].Pos(), "expected variable to configure")
+		}
+		spec.Config = kws
+	}
+	spec.EndPos = p.pos
+	p.loadModule(spec, spec.Path)
+	p.expectSemi()
+
+	return &ast.GenDecl{
+		TokPos: pos,
+		Tok:    token.USE,
+		Specs:  []ast.Spec{spec},
+	}
+}
+
+// @forward "path" as prefix-* show a, $b hide c;
+func (p *parser) parseForwardDecl() *ast.GenDecl {
+	if p.trace {
+		defer un(trace(p, "ForwardDecl"))
+	}
+
+	pos := p.expect(token.FORWARD)
+	spec := &ast.ForwardSpec{Forward: pos, Path: p.parseModulePath()}
+	for p.tok == token.STRING {
+		switch p.lit {
+		case "as":
+			p.next()
+			if p.tok != token.STRING || !strings.HasSuffix(p.lit, "*") {
+				p.errorExpected(p.pos, "prefix ending in *")
+			}
+			spec.Prefix = strings.TrimSuffix(p.lit, "*")
+			p.next()
+		case "show":
+			p.next()
+			spec.Show = p.parseMemberList()
+		case "hide":
+			p.next()
+			spec.Hide = p.parseMemberList()
+		default:
+			p.errorExpected(p.pos, "as, show or hide")
+			p.next()
+		}
+	}
+	spec.EndPos = p.pos
+	p.loadModule(spec, spec.Path)
+	p.expectSemi()
+
+	return &ast.GenDecl{
+		TokPos: pos,
+		Tok:    token.FORWARD,
+		Specs:  []ast.Spec{spec},
+	}
+}
+
+// parseModulePath parses the quoted path of @use and @forward
+func (p *parser) parseModulePath() *ast.BasicLit {
+	lit := &ast.BasicLit{ValuePos: p.pos, Kind: token.QSTRING}
+	if p.tok != token.QSTRING && p.tok != token.QSSTRING {
+		p.errorExpected(p.pos, "module path")
+		return lit
+	}
+	x := p.parseString()
+	path, ok := x.List[0].(*ast.BasicLit)
+	if !ok || len(x.List) != 1 {
+		p.error(x.Pos(), "module path can not be interpolated")
+		return lit
+	}
+	lit.Value = path.Value
+	return lit
+}
+
+// parseMemberList parses the names listed by show and hide
+func (p *parser) parseMemberList() []string {
+	var names []string
+	for p.tok == token.STRING || p.tok == token.VAR {
+		names = append(names, p.lit)
+		p.next()
+		if p.tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	if len(names) == 0 {
+		p.errorExpected(p.pos, "member name")
+	}
+	return names
+}
+
+// namespace is the default namespace of a module, the name of the file
+// without its extension or leading underscore
+func namespace(path string) string {
+	path = strings.TrimPrefix(path, "sass:")
+	base := filepath.Base(path)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	return strings.TrimPrefix(base, "_")
+}
+
+// loadModule makes the members of the module at path available to
+// spec. Modules are parsed after the statement, like imports, unless
+// they were loaded before.
+func (p *parser) loadModule(spec ast.Spec, path *ast.BasicLit) {
+	if p.modules == nil {
+		p.modules = make(map[string]*module)
+	}
+	var config []*ast.KeyValueExpr
+	if use, ok := spec.(*ast.UseSpec); ok {
+		config = use.Config
+	}
+
+	if strings.HasPrefix(path.Value, "sass:") {
+		m, ok := builtinModule(strings.TrimPrefix(path.Value, "sass:"))
+		if !ok {
+			p.error(path.Pos(), "unknown built-in module: "+path.Value)
+			return
+		}
+		if len(config) > 0 {
+			p.error(path.Pos(), "built-in modules can not be configured")
+		}
+		p.bindModule(spec, m)
+		return
+	}
+
+	abs, err := p.locate(path.Value)
+	if err != nil {
+		p.error(path.Pos(), "failed to load module: "+err.Error())
+		return
+	}
+	if m, ok := p.modules[abs]; ok {
+		switch {
+		case m.exports == nil:
+			p.error(path.Pos(), "module loop: "+path.Value+
+				" is already being loaded")
+		case len(config) > 0:
+			p.error(path.Pos(), path.Value+" was already loaded, "+
+				"so it can not be configured using with")
+		default:
+			p.bindModule(spec, m)
+		}
+		return
+	}
+	m := newModule(abs)
+	p.modules[abs] = m
+	p.queue = append(p.queue, &queue{filename: abs, module: m, spec: spec, pos: spec.Pos()})
+}
+
+// enterModule gives the module loaded by q its own global scope, the
+// scopes of the file loading it are restored by leaveModule
+func (p *parser) enterModule(q *queue) {
+	stk := &p.imps[len(p.imps)-1]
+	stk.load = q
+	stk.pkgScope, stk.topScope, stk.module = p.pkgScope, p.topScope, p.module
+
+	p.module = q.module
+	p.topScope = ast.NewScope(nil)
+	p.pkgScope = p.topScope
+	if use, ok := q.spec.(*ast.UseSpec); ok {
+		// configured variables take the place of !default ones
+		for _, kv := range use.Config {
+			name := kv.Key.(*ast.Ident)
+			ident := ast.NewIdent(name.Name)
+			obj := ast.NewObj(ast.Var, name.Name)
+			obj.Decl = &ast.AssignStmt{
+				Lhs:    []ast.Expr{ident},
+				TokPos: name.Pos(),
+				Tok:    token.COLON,
+				Rhs:    []ast.Expr{kv.Value},
+			}
+			ident.Obj = obj
+			p.topScope.Insert(obj, false)
+		}
+	}
+}
+
+// leaveModules leaves the modules parsed to the end. The parser reads
+// past the end of a module before finishing its last declaration, so
+// modules are left once the declaration is done.
+func (p *parser) leaveModules() {
+	for _, stk := range p.leaving {
+		p.leaveModule(stk)
+	}
+	p.leaving = nil
+}
+
+// leaveModule records the members of the module that finished parsing
+// and binds them to the file that loaded it
+func (p *parser) leaveModule(stk stack) {
+	m := p.module
+	m.exports = ast.NewScope(nil)
+	for name, obj := range m.forwards.Objects {
+		m.exports.Objects[name] = obj
+	}
+	for name, obj := range p.pkgScope.Objects {
+		// members starting with - or _ are private
+		if obj.Kind == ast.Pkg || strings.HasPrefix(name, "-") ||
+			strings.HasPrefix(name, "_") || strings.HasPrefix(name, "$-") ||
+			strings.HasPrefix(name, "$_") {
+			continue
+		}
+		m.exports.Objects[name] = obj
+	}
+	if use, ok := stk.load.spec.(*ast.UseSpec); ok {
+		for _, kv := range use.Config {
+			name := kv.Key.(*ast.Ident)
+			obj := p.pkgScope.Lookup(name.Name)
+			if obj == nil || obj.Decl.(*ast.AssignStmt).TokPos != name.Pos() {
+				p.error(name.Pos(), name.Name+
+					" was not declared with !default in the module")
+			}
+		}
+	}
+
+	p.pkgScope, p.topScope, p.module = stk.pkgScope, stk.topScope, stk.module
+	p.bindModule(stk.load.spec, m)
+}
+
+// bindModule makes the members of m available to the file parsed.
+// @use binds them to a namespace, @forward to the users of the file.
+func (p *parser) bindModule(spec ast.Spec, m *module) {
+	switch spec := spec.(type) {
+	case *ast.UseSpec:
+		if spec.Name == "*" {
+			p.module.globals = append(p.module.globals, m.exports)
+			return
+		}
+		if obj := p.pkgScope.Lookup(spec.Name); obj != nil && obj.Kind == ast.Pkg {
+			p.error(spec.Pos(), "there is already a module with namespace "+
+				spec.Name)
+			return
+		}
+		obj := ast.NewObj(ast.Pkg, spec.Name)
+		obj.Decl = spec
+		obj.Data = m.exports
+		p.pkgScope.Insert(obj, false)
+	case *ast.ForwardSpec:
+		for name, obj := range m.exports.Objects {
+			if spec.Prefix != "" {
+				if strings.HasPrefix(name, "$") {
+					name = "$" + spec.Prefix + name[1:]
+				} else {
+					name = spec.Prefix + name
+				}
+			}
+			if spec.Show != nil && !hasName(spec.Show, name) ||
+				hasName(spec.Hide, name) {
+				continue
+			}
+			p.module.forwards.Objects[name] = obj
+		}
+	}
+}
+
+func hasName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupMember finds name among the members of the modules loaded by
+// @use, ie. ns.$var, ns.fn or members of modules used with as *
+func (p *parser) lookupMember(name string) *ast.Object {
+	if p.module == nil {
+		return nil
+	}
+	i := strings.Index(name, ".")
+	if i < 0 {
+		for _, scope := range p.module.globals {
+			if obj := scope.Lookup(name); obj != nil {
+				return obj
+			}
+		}
+		return nil
+	}
+	for s := p.topScope; s != nil; s = s.Outer {
+		if obj := s.Lookup(name[:i]); obj != nil && obj.Kind == ast.Pkg {
+			return obj.Data.(*ast.Scope).Lookup(name[i+1:])
+		}
+	}
+	return nil
+}
+
+// memberError explains why the namespaced member name, ie. ns.$var or
+// ns.fn, was not found. Empty if name is not namespaced.
+func (p *parser) memberError(name string) string {
+	i := strings.Index(name, ".")
+	if i < 0 {
+		return ""
+	}
+	ns, member := name[:i], name[i+1:]
+	var obj *ast.Object
+	for s := p.topScope; s != nil && obj == nil; s = s.Outer {
+		obj = s.Lookup(ns)
+	}
+	switch {
+	case obj == nil || obj.Kind != ast.Pkg:
+		return "There is no module with the namespace \"" + ns + "\"."
+	case strings.HasPrefix(member, "$-") || strings.HasPrefix(member, "$_"):
+		return "Private members can't be accessed from outside their modules."
+	case !isVar(name):
+		return "Undefined function: \"" + name + "\"."
+	}
+	return "Undefined variable: \"" + name + "\"."
+}
+
+// isVar reports whether name is a variable ie. $x or ns.$x
+func isVar(name string) bool {
+	return strings.HasPrefix(name, "$") || strings.Contains(name, ".$")
+}
+
+// moduleScope returns the global scope of the module declaring the
+// function or mixin obj
+func (p *parser) moduleScope(obj *ast.Object) *ast.Scope {
+	if scope, ok := obj.Data.(*ast.Scope); ok {
+		return scope
+	}
+	return p.pkgScope
+}
+
+// builtinMember returns the global builtin behind a member of a
+// built-in module ie. map.get is map-get
+func (p *parser) builtinMember(name string) (string, bool) {
+	ident := ast.NewIdent(name)
+	p.tryResolve(ident, false)
+	if ident.Obj == nil {
+		return "", false
+	}
+	fn, ok := ident.Obj.Data.(string)
+	return fn, ok
+}
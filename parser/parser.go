@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"math"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -29,6 +29,14 @@ type stack struct {
 	lit     string
 	syncPos token.Pos
 	syncCnt int
+	queue   []*queue  // imports waiting for the current one to finish
+	from    token.Pos // position of the import of the next file
+
+	// Scopes of the file loading a module, restored once it is parsed
+	load     *queue
+	pkgScope *ast.Scope
+	topScope *ast.Scope
+	module   *module
 }
 
 type triplet struct {
@@ -40,6 +48,9 @@ type triplet struct {
 type queue struct {
 	filename string
 	src      interface{}
+	module   *module   // module loaded by spec; or nil for imports
+	spec     ast.Spec  // @use or @forward loading module
+	pos      token.Pos // position of the import
 }
 
 // The parser structure holds the parser's internal state.
@@ -51,7 +62,7 @@ type parser struct {
 	// Parser state is pushed onto importStack while imports
 	// are being scanned and parsed.
 	imps      []stack
-	queue     *queue // queued file for import, starts a new scanner
+	queue     []*queue // queued files for import, each starts a new scanner
 	lookahead triplet
 	inSel     bool // controler selector logic
 	prescan   bool // control interpolation joining
@@ -83,7 +94,19 @@ type parser struct {
 	exprLev int            // < 0: in control clause, >= 0: in expression
 	inRhs   bool           // if set, the parser is parsing a rhs expression
 	inMixin bool           // special rules for mixins
+	loops   int            // number of enclosing loops, the outermost unrolls
+	lazy    int            // > 0: in arguments of if(), calls wait for it
 	sels    []*ast.SelStmt // current list of nested selectors
+	rootLev int            // len(sels)+1 directly inside of @at-root
+	media   int            // number of enclosing @media
+	escaped []ast.Stmt     // @at-root waiting to leave the enclosing @media
+
+	// Queries of the enclosing @media, nested @media are merged into these
+	queries []*ast.MediaQuery
+
+	maxIterations int              // limit of @while iterations
+	pending       []*ast.DebugStmt // @debug and @warn of functions called by the statement being parsed
+	importer      Importer         // finds and loads imported files
 
 	// Ordinary identifier scopes
 	pkgScope   *ast.Scope        // pkgScope.Outer == nil
@@ -91,6 +114,15 @@ type parser struct {
 	unresolved []*ast.Ident      // unresolved identifiers
 	imports    []*ast.ImportSpec // list of imports
 
+	// Modules loaded by @use and @forward
+	module  *module            // module being parsed
+	modules map[string]*module // loaded modules by path
+	leaving []stack            // modules parsed to the end
+
+	// Dependencies of the file, watchers rebuild when they change
+	deps  []string // files read by imports
+	globs []string // patterns of glob imports
+
 	// Label scopes
 	// (maintained by open/close LabelScope)
 	labelScope  *ast.Scope     // label scope for current function
@@ -101,10 +133,26 @@ var Globalfset *token.FileSet
 
 func (p *parser) init(fset *token.FileSet, filename string, src []byte, mode Mode) {
 	Globalfset = fset
+	// .scss and .sass may import each other, the extension of
+	// imports picks their syntax
+	eh := func(pos token.Position, msg string) { p.errors.Add(pos, msg) }
+	// translation keeps the lines, the errors only miss the file
+	ieh := func(pos token.Position, msg string) {
+		pos.Filename = filename
+		eh(pos, msg)
+	}
+	switch filepath.Ext(filename) {
+	case ".sass":
+		src = scanner.Indented(src, ieh)
+	case ".scss", ".css":
+	default:
+		if mode&Indented != 0 {
+			src = scanner.Indented(src, ieh)
+		}
+	}
 	p.file = fset.AddFile(filename, -1, len(src))
 	var m scanner.Mode
 	m = scanner.ScanComments
-	eh := func(pos token.Position, msg string) { p.errors.Add(pos, msg) }
 	p.scanner.Init(p.file, src, eh, m)
 
 	p.mode = mode
@@ -115,49 +163,84 @@ func (p *parser) init(fset *token.FileSet, filename string, src []byte, mode Mod
 
 // add opens a new file and starts scanning it. It preserves the previous
 // scanner and position in the importStack stack
-func (p *parser) add(filename string, src interface{}) error {
-	// imports are relative to the parent
-	path := filepath.Join(filepath.Dir(p.file.Name()), filename)
-	exts := []string{".scss", ".sass"}
-	for i := range exts {
-		if !strings.HasSuffix(path, exts[i]) {
-			// attempt adding extension
-			_, err := os.Stat(path + exts[i])
-			if err == nil {
-				path += exts[i]
-				break
-			}
+func (p *parser) add(pos token.Pos, filename string) error {
+	abs, err := p.locate(filename)
+	if err != nil {
+		return err
+	}
+	return p.enqueue(&queue{filename: abs, pos: pos})
+}
 
-			// Attempt partial
-			base := filepath.Base(path)
-			p := strings.Replace(path, base, "_"+base+exts[i], 1)
-			_, err = os.Stat(p)
-			if err == nil {
-				path = p
-				break
-			}
-		} else {
-			base := filepath.Base(path)
-			p := strings.Replace(path, base, "_"+base, 1)
-			_, err := os.Stat("_" + p)
-			if err == nil {
-				path = p
-				break
-			}
+// enqueue queues the import q unless it imports one of the files
+// importing it
+func (p *parser) enqueue(q *queue) error {
+	if err := p.importLoop(q.pos, q.filename); err != nil {
+		return err
+	}
+	p.queue = append(p.queue, q)
+	return nil
+}
+
+// importLoop reports the chain of imports leading to filename when
+// filename is one of the files being imported
+func (p *parser) importLoop(pos token.Pos, filename string) error {
+	type link struct {
+		file *token.File
+		pos  token.Pos
+	}
+	chain := make([]link, 0, len(p.imps)+1)
+	for _, stk := range p.imps {
+		chain = append(chain, link{stk.file, stk.from})
+	}
+	chain = append(chain, link{p.file, pos})
+	loop := false
+	for _, l := range chain {
+		loop = loop || l.file.Name() == filename
+	}
+	if !loop {
+		return nil
+	}
+	// the chain is listed from the file being compiled
+	steps := make([]string, len(chain))
+	for i, l := range chain {
+		next := filename
+		if i+1 < len(chain) {
+			next = chain[i+1].file.Name()
 		}
+		steps[i] = fmt.Sprintf("%s imports %s", l.file.Position(l.pos), next)
+	}
+	return fmt.Errorf("import loop: %s", strings.Join(steps, ", "))
+}
 
+// imported reports whether q imports a file read before, ImportOnce
+// skips these
+func (p *parser) imported(q *queue) bool {
+	if q.module != nil {
+		return false
+	}
+	for _, name := range p.deps {
+		if name == q.filename {
+			return true
+		}
 	}
-	abs, err := filepath.Abs(path)
+	return false
+}
+
+// locate finds the file imported as filename with the importer
+func (p *parser) locate(filename string) (string, error) {
+	name, err := p.importer.Canonicalize(filename, p.file.Name())
 	if err != nil {
-		return err
+		return "", err
 	}
-	p.queue = &queue{filename: abs, src: src}
-	return nil
+	if name == "" {
+		return "", fmt.Errorf("file to import not found: %s", filename)
+	}
+	return name, nil
 }
 
 func (p *parser) pop() error {
-	if p.queue == nil {
-		return fmt.Errorf("pop() called with nil queue")
+	if len(p.queue) == 0 {
+		return fmt.Errorf("pop() called with empty queue")
 	}
 	stk := stack{
 		file:    p.file,
@@ -167,12 +250,21 @@ func (p *parser) pop() error {
 		lit:     p.lit,
 		syncPos: p.syncPos,
 		syncCnt: p.syncCnt,
+		queue:   p.queue[1:],
+		from:    p.queue[0].pos,
 	}
 	p.imps = append(p.imps, stk)
 
-	filename, src := p.queue.filename, p.queue.src
+	q := p.queue[0]
+	filename, src := q.filename, q.src
 	p.queue = nil
-	text, err := readSource(filename, src)
+	var text []byte
+	var err error
+	if src != nil {
+		text, err = readSource(filename, src)
+	} else {
+		text, err = p.importer.Load(filename)
+	}
 	if err != nil {
 		abs, ferr := filepath.Abs(filename)
 		if ferr != nil {
@@ -181,8 +273,9 @@ func (p *parser) pop() error {
 		err = fmt.Errorf("failed to read: %s", err, abs)
 		return err
 	}
-	if p.queue != nil {
-		panic("queue hasn't been flushed")
+	p.deps = append(p.deps, filename)
+	if q.module != nil {
+		p.enterModule(q)
 	}
 	p.init(Globalfset, filename, text, p.mode)
 
@@ -293,6 +386,12 @@ func (p *parser) shortVarDecl(decl *ast.AssignStmt, list []ast.Expr) {
 				fmt.Printf("new resolve %s\n", ident)
 			}
 			// assert(ident.Obj == nil, "identifier already declared or resolved")
+			if ident.Default {
+				if obj := p.lookupDefault(ident); obj != nil {
+					ident.Obj = obj
+					continue
+				}
+			}
 			obj := ast.NewObj(ast.Var, ident.Name)
 			// remember corresponding assignment for other tools
 			obj.Decl = decl
@@ -321,6 +420,27 @@ func (p *parser) shortVarDecl(decl *ast.AssignStmt, list []ast.Expr) {
 	}
 }
 
+// lookupDefault finds the variable a !default assignment would replace.
+// Variables set to null are not considered defined.
+func (p *parser) lookupDefault(ident *ast.Ident) *ast.Object {
+	for s := p.topScope; s != nil; s = s.Outer {
+		if ident.Global && s.Outer != nil {
+			continue
+		}
+		obj := s.Lookup(ident.Name)
+		if obj == nil {
+			continue
+		}
+		if ass, ok := obj.Decl.(*ast.AssignStmt); ok && len(ass.Rhs) == 1 {
+			if lit, ok := ass.Rhs[0].(*ast.BasicLit); ok && lit.Kind == token.NULL {
+				return nil
+			}
+		}
+		return obj
+	}
+	return nil
+}
+
 // The unresolved object is a sentinel to mark identifiers that have been added
 // to the list of unresolved identifiers. The sentinel is only used for verifying
 // internal consistency.
@@ -357,6 +477,10 @@ func (p *parser) tryResolve(x ast.Expr, collectUnresolved bool) {
 			return
 		}
 	}
+	if obj := p.lookupMember(ident.Name); obj != nil {
+		ident.Obj = obj
+		return
+	}
 
 	// This is a significant failure scenario. However, inside
 	// mixins failing to resolve identifiers are perfectly valid.
@@ -368,6 +492,10 @@ func (p *parser) tryResolve(x ast.Expr, collectUnresolved bool) {
 	// (perhaps in another file), or universe scope --- collect
 	// them so that they can be resolved later
 	if collectUnresolved && !p.inMixin && p.mode&FuncOnly == 0 {
+		// namespaces are known, their members can't resolve later
+		if msg := p.memberError(ident.Name); msg != "" && isVar(ident.Name) {
+			p.error(ident.Pos(), msg)
+		}
 		fmt.Printf("failed to resolve % #v\n", ident)
 		// panic("boom")
 		ident.Obj = unresolved
@@ -434,7 +562,12 @@ func (p *parser) next0() {
 	// with queueing logic to prevent any un(trace()) calls from
 	// from the parent file being executed after the sub-file parser
 	// is running.
-	if p.queue != nil {
+	if p.mode&ImportOnce != 0 {
+		for len(p.queue) > 0 && p.imported(p.queue[0]) {
+			p.queue = p.queue[1:]
+		}
+	}
+	if len(p.queue) > 0 {
 		err := p.pop()
 		if err != nil {
 			p.error(p.pos, fmt.Sprintf("error reading queue: %s", err))
@@ -457,6 +590,10 @@ func (p *parser) next0() {
 			p.lit = pop.lit
 			p.syncPos = pop.syncPos
 			p.syncCnt = pop.syncCnt
+			p.queue = pop.queue
+			if pop.load != nil {
+				p.leaving = append(p.leaving, pop)
+			}
 			p.next()
 		}
 	}
@@ -592,6 +729,24 @@ func (p *parser) error(pos token.Pos, msg string) {
 	p.errors.Add(epos, msg)
 }
 
+// A raisedError is reported at its own position rather than at the
+// call, ie. @error in a function or an undefined function name
+type raisedError struct {
+	pos token.Position
+	msg string
+}
+
+func (e *raisedError) Error() string { return e.msg }
+
+// errorAt reports err of an expression at pos
+func (p *parser) errorAt(pos token.Pos, err error) {
+	if e, ok := err.(*raisedError); ok {
+		p.errors.Add(e.pos, e.msg)
+		return
+	}
+	p.error(pos, err.Error())
+}
+
 func (p *parser) errorExpected(pos token.Pos, msg string) {
 	msg = "expected " + msg
 	if pos == p.pos {
@@ -759,10 +914,20 @@ func (p *parser) resolveCall(x ast.Expr) (ast.Expr, error) {
 	case *ast.BasicLit:
 	case *ast.CallExpr:
 		// hold on soldier, first lets resolve all arguments
+		// except those of if(), it evaluates the branch it selects
+		if isIf(v) {
+			return evaluateCall(p, p.topScope, v)
+		}
 		for i := range v.Args {
+			if _, ok := spreadIdent(v.Args[i]); ok {
+				continue
+			}
 			p.resolveExpr(p.topScope, v.Args[i])
 		}
-		return evaluateCall(p, p.topScope, v)
+		// calls passed as arguments are read from Resolved
+		res, err := evaluateCall(p, p.topScope, v)
+		v.Resolved = res
+		return res, err
 	case *ast.BinaryExpr:
 		l, err := p.resolveCall(v.X)
 		if err != nil {
@@ -779,6 +944,10 @@ func (p *parser) resolveCall(x ast.Expr) (ast.Expr, error) {
 	// 	l, _ := p.resolveCall(v.X)
 	// 	v.X = l
 	// 	x = v
+	case *ast.UnaryExpr:
+		if v.Op == token.NEST {
+			return p.parentSel(v.OpPos), nil
+		}
 	case *ast.Ident:
 		if v.Obj == nil {
 			p.resolve(x)
@@ -807,17 +976,26 @@ func (p *parser) resolveInterp(scope *ast.Scope, itp *ast.Interp) {
 		}
 		res, err := calc.Resolve(x, true)
 		if err != nil {
-			p.error(x.Pos(), err.Error())
+			// arguments of mixins and functions are bound when the
+			// body is resolved again
+			if !p.inMixin {
+				p.error(x.Pos(), err.Error())
+			}
 			continue
 		}
-		if res.Kind != token.STRING {
+		switch res.Kind {
+		case token.STRING:
+		case token.NULL:
+			// null interpolates to nothing
+			res.Value = ""
+		default:
 			res.Value = strops.Unquote(res.Value)
 		}
 		// Append value to interp
 		if res.Pos() >= itp.End() {
 			merge = true
 		}
-		if !merge {
+		if !merge || len(ss) == 0 {
 			ss = append(ss, res.Value)
 		} else {
 			merge = false
@@ -907,49 +1085,163 @@ func (p *parser) parseSassList(lhs, canComma bool) (list []ast.Expr, hasComma, c
 	if p.trace {
 		defer un(trace(p, "SassList"))
 	}
-	if p.tok == token.LPAREN {
-		checkParen = true
-		p.next()
-	}
 	if p.tok == token.RULE {
 		p.error(p.pos, "sass can not contain a list")
 		p.next()
 	}
-	for p.tok != token.SEMICOLON &&
-		// possible closers
-		p.tok != token.LBRACE && p.tok != token.RPAREN &&
-		p.tok != token.RBRACE &&
-		// failure scenario
-		p.tok != token.EOF {
-		if canComma {
-			inner := p.listFromExprs(p.parseSassList(lhs, false))
+	if !canComma {
+		list = p.parseSpaceList(lhs)
+	}
+	for canComma && !p.listEnd() {
+		inner := p.listFromExprs(p.parseSpaceList(lhs), false, false)
+		if inner != nil {
 			list = append(list, inner)
-			if p.tok == token.COMMA {
-				hasComma = true
-				p.next()
-			}
-		} else if p.tok == token.LPAREN {
-			// fuck, new list
-			list = append(list, p.listFromExprs(p.parseSassList(lhs, true)))
-		} else if p.tok == token.COMMA {
-			return
-		} else {
-			x := p.inferExpr(lhs, checkParen)
-			if interp, ok := x.(*ast.Interp); ok {
-				p.resolveInterp(p.topScope, interp)
-			}
-			list = append(list, p.checkExpr(x))
 		}
+		if p.tok != token.COMMA {
+			break
+		}
+		hasComma = true
+		p.next()
 	}
 
 	if p.tok == token.EOF {
 		p.error(p.pos, "EOF reached before list end")
 	}
-	if checkParen {
-		p.expect(token.RPAREN)
+	// A list wrapped in parens ie. (1 2 3) is returned as its
+	// elements, groups inside a list (a b) (c d) remain lists.
+	if len(list) == 1 && !hasComma {
+		if l, ok := list[0].(*ast.ListLit); ok &&
+			l.Paren && !l.Bracket && l.Keywords == nil {
+			return l.Value, l.Comma, true
+		}
+	}
+	return
+}
+
+// parseSpaceList parses the space delimited elements of a list up
+// to the next comma or closer
+func (p *parser) parseSpaceList(lhs bool) (list []ast.Expr) {
+	// a colon ends the first key of a map ie. ("a": 1)
+	for !p.listEnd() && p.tok != token.COMMA && p.tok != token.COLON {
+		x := p.inferExpr(lhs, false)
+		if interp, ok := x.(*ast.Interp); ok {
+			p.resolveInterp(p.topScope, interp)
+		}
+		list = append(list, p.checkExpr(x))
 	}
 	return
+}
+
+// listEnd reports whether the current token closes a list
+func (p *parser) listEnd() bool {
+	switch p.tok {
+	case token.SEMICOLON,
+		// possible closers
+		token.LBRACE, token.RPAREN, token.RBRACK, token.RBRACE,
+		// failure scenario
+		token.EOF:
+		return true
+	}
+	return false
+}
+
+// parseParenList parses a list wrapped in parens or square brackets.
+// Parens may also hold a map or a single value for math ie. (1 + 2)
+//
+// (a b), (a,), [a b], (key: value)
+func (p *parser) parseParenList(lhs bool) ast.Expr {
+	if p.trace {
+		defer un(trace(p, "ParenList"))
+	}
+	open, closer := p.tok, token.RPAREN
+	if open == token.LBRACK {
+		closer = token.RBRACK
+	}
+	lparen := p.expect(open)
+	if open == token.LPAREN && p.tok == token.RULE {
+		// (key: value) is a map
+		return p.parseMapLit(lparen, nil)
+	}
+	list, hasComma, _ := p.parseSassList(lhs, true)
+	if open == token.LPAREN && p.tok == token.COLON && len(list) == 1 && !hasComma {
+		// the first key is quoted or a number ie. ("a": 1)
+		return p.parseMapLit(lparen, list[0])
+	}
+	rparen := p.expectClosing(closer, "list")
+	single := len(list) == 1 && hasComma
+	if open == token.LPAREN && len(list) > 0 && !single {
+		return p.listFromExprs(list, hasComma, true)
+	}
+	if len(list) == 1 && !hasComma {
+		// [a b] is parsed as a single space delimited list
+		if l, ok := list[0].(*ast.ListLit); ok && !l.Paren && !l.Bracket {
+			list, hasComma = l.Value, l.Comma
+		}
+	}
+	return &ast.ListLit{
+		ValuePos: lparen,
+		EndPos:   rparen + 1,
+		Value:    list,
+		Comma:    hasComma,
+		Paren:    open == token.LPAREN,
+		Bracket:  open == token.LBRACK,
+	}
+}
+
+// parseMapLit parses the pairs of a map, the opening paren has
+// already been consumed. first is the first key when it was parsed
+// before the map was recognized; or nil.
+//
+// (key: value, nested: (a: b))
+func (p *parser) parseMapLit(lparen token.Pos, first ast.Expr) *ast.MapLit {
+	if p.trace {
+		defer un(trace(p, "MapLit"))
+	}
+	m := &ast.MapLit{Lparen: lparen}
+	for p.tok != token.RPAREN && p.tok != token.EOF {
+		var key ast.Expr
+		switch {
+		case first != nil:
+			key = p.mapKey(first)
+			first = nil
+		case p.tok == token.RULE:
+			key = &ast.BasicLit{
+				ValuePos: p.pos,
+				Kind:     token.STRING,
+				Value:    p.lit,
+			}
+			p.next()
+		default:
+			key = p.mapKey(p.inferExpr(false, true))
+		}
+		colon := p.expect(token.COLON)
+		val := p.listFromExprs(p.parseSassList(false, false))
+		if val == nil {
+			p.errorExpected(colon, "map value")
+			val = &ast.BadExpr{From: colon, To: p.pos}
+		}
+		m.Elts = append(m.Elts, &ast.KeyValueExpr{
+			Key:   key,
+			Colon: colon,
+			Value: val,
+		})
+		if p.tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	m.Rparen = p.expect(token.RPAREN)
+	return m
+}
 
+// mapKey resolves the expression of a map key to its value
+func (p *parser) mapKey(x ast.Expr) ast.Expr {
+	lit, err := calc.Resolve(x, true)
+	if err != nil {
+		p.error(x.Pos(), err.Error())
+		lit = &ast.BasicLit{ValuePos: x.Pos()}
+	}
+	return lit
 }
 
 func (p *parser) expandList(in []ast.Expr) []ast.Expr {
@@ -958,12 +1250,18 @@ func (p *parser) expandList(in []ast.Expr) []ast.Expr {
 		return in
 	}
 
+	if m, ok := in[0].(*ast.MapLit); ok {
+		return mapPairs(m)
+	}
+
 	ident, ok := in[0].(*ast.Ident)
 	if !ok {
 		return in
 	}
 
-	p.tryResolve(ident, false)
+	if ident.Obj == nil {
+		p.tryResolve(ident, false)
+	}
 	if ident.Obj == nil {
 		// uninitialized variable
 		return in
@@ -974,12 +1272,27 @@ func (p *parser) expandList(in []ast.Expr) []ast.Expr {
 		return in
 	}
 
-	list, ok := ass.Rhs[0].(*ast.ListLit)
-	if !ok {
-		return in
+	switch v := ass.Rhs[0].(type) {
+	case *ast.ListLit:
+		return v.Value
+	case *ast.MapLit:
+		return mapPairs(v)
+	}
+	return in
+}
+
+// mapPairs converts a map to a list of key value pairs, this is how
+// maps are iterated
+func mapPairs(m *ast.MapLit) []ast.Expr {
+	out := make([]ast.Expr, len(m.Elts))
+	for i, kv := range m.Elts {
+		out[i] = &ast.ListLit{
+			ValuePos: kv.Pos(),
+			EndPos:   kv.End(),
+			Value:    []ast.Expr{kv.Key, kv.Value},
+		}
 	}
-
-	return list.Value
+	return out
 }
 
 func (p *parser) inferLhsList() ast.Expr {
@@ -1147,6 +1460,10 @@ func (p *parser) inferExprList(lhs bool) ast.Expr {
 // listFromExprs takes a slice of expr to create a ListLit
 func (p *parser) listFromExprs(in []ast.Expr, hasComma, inParen bool) ast.Expr {
 	if len(in) == 0 {
+		if inParen {
+			// () is an empty list
+			return &ast.ListLit{Paren: true}
+		}
 		return nil
 	}
 	if len(in) > 1 {
@@ -1194,6 +1511,10 @@ func (p *parser) parseString() *ast.StringExpr {
 	// Only strings and interpolations allowed here
 	for p.tok != token.EOF && p.tok != tok {
 		x := p.inferExpr(false, false)
+		if lit, ok := x.(*ast.BasicLit); ok && lit.Kind == token.NULL {
+			// "null" is a string
+			lit.Kind = token.STRING
+		}
 		list = append(list, x)
 	}
 	rquote := p.expectClosing(tok, "string list")
@@ -1238,7 +1559,7 @@ func (p *parser) parseSassType() ast.Expr {
 		defer un(trace(p, "SassType"))
 	}
 	var expr ast.Expr
-	if p.lit[0] == '$' {
+	if isVar(p.lit) {
 		// This is too open, need more checks
 		lit := p.lit
 		ident := &ast.Ident{
@@ -1430,6 +1751,9 @@ func (p *parser) tryIdentOrType() ast.Expr {
 	case token.LPAREN:
 		lparen := p.pos
 		p.next()
+		if p.tok == token.RULE {
+			return p.parseMapLit(lparen, nil)
+		}
 		typ := p.parseType()
 		rparen := p.expect(token.RPAREN)
 		return &ast.ParenExpr{Lparen: lparen, X: typ, Rparen: rparen}
@@ -1439,7 +1763,7 @@ func (p *parser) tryIdentOrType() ast.Expr {
 		// FIXME: we should verify types here, ie. UPX, INT, IDENT
 		expr := &ast.BasicLit{
 			ValuePos: p.pos,
-			Kind:     p.tok,
+			Kind:     litKind(p.tok, p.lit),
 			Value:    p.lit,
 		}
 		p.next()
@@ -1483,6 +1807,121 @@ func (p *parser) unwrapInclude(in ast.Stmt) []ast.Stmt {
 // ----------------------------------------------------------------------------
 // Blocks
 
+// @font-face { ... }
+// @keyframes #{$name} { ... }
+// @charset "UTF-8";
+func (p *parser) parseDirectiveStmt() *ast.DirectiveStmt {
+	if p.trace {
+		defer un(trace(p, "DirectiveStmt"))
+	}
+
+	stmt := &ast.DirectiveStmt{At: p.pos, Name: p.lit}
+	p.expect(token.DIRECTIVE)
+	for p.tok == token.STRING || p.tok == token.INTERP {
+		if p.tok == token.STRING {
+			stmt.Params = append(stmt.Params, &ast.BasicLit{
+				ValuePos: p.pos,
+				Kind:     token.STRING,
+				Value:    p.lit,
+			})
+			p.next()
+			continue
+		}
+		stmt.Params = append(stmt.Params, p.parseInterp())
+	}
+	// Mixin arguments are not known yet, the copy is resolved
+	if !p.inMixin {
+		p.resolveDirectiveParams(stmt)
+	}
+	p.directiveSel(stmt)
+	if p.tok != token.LBRACE {
+		p.expectSemi()
+		return stmt
+	}
+
+	if isKeyframes(stmt) {
+		stmt.Body = p.parseKeyframes()
+		return stmt
+	}
+	p.openScope()
+	stmt.Body = p.parseBody(p.topScope)
+	p.closeScope()
+	return stmt
+}
+
+// resolveDirectiveParams merges the parameters of a directive into its
+// prelude. Keyframe blocks have no parameters and keep their prelude.
+func (p *parser) resolveDirectiveParams(stmt *ast.DirectiveStmt) {
+	if len(stmt.Params) == 0 {
+		return
+	}
+	for _, x := range stmt.Params {
+		if itp, ok := x.(*ast.Interp); ok {
+			p.resolveInterp(p.topScope, itp)
+		}
+	}
+	s, _ := itpMerge(stmt.Params)
+	stmt.Prelude = &ast.BasicLit{
+		ValuePos: stmt.Params[0].Pos(),
+		Kind:     token.STRING,
+		Value:    s,
+	}
+}
+
+func isKeyframes(stmt *ast.DirectiveStmt) bool {
+	return strings.HasSuffix(stmt.Name, "keyframes")
+}
+
+// directiveSel records the selector a directive was nested in, rules
+// in its body belong to it. Keyframes do not use the selector.
+func (p *parser) directiveSel(stmt *ast.DirectiveStmt) {
+	stmt.Sel = nil
+	if len(p.sels) > 0 && stmt.Name != "" && !isKeyframes(stmt) {
+		stmt.Sel = p.sels[len(p.sels)-1].Resolved
+	}
+}
+
+// parseKeyframes reads the body of @keyframes. The selectors of its
+// blocks ie. from, 50% are never joined with the parent selector.
+func (p *parser) parseKeyframes() *ast.BlockStmt {
+	if p.trace {
+		defer un(trace(p, "Keyframes"))
+	}
+
+	lbrace := p.expect(token.LBRACE)
+	sels := p.sels
+	p.sels = nil
+	var list []ast.Stmt
+	for p.tok != token.RBRACE && p.tok != token.EOF {
+		pos := p.pos
+		var ss []string
+		if p.tok == token.SELECTOR {
+			// The raw selector is followed by its pieces
+			ss = append(ss, p.lit)
+			for p.tok != token.LBRACE && p.tok != token.EOF {
+				p.next()
+			}
+		}
+		for p.tok != token.LBRACE && p.tok != token.EOF {
+			if p.tok != token.COMMA {
+				ss = append(ss, p.lit)
+			}
+			p.next()
+		}
+		sel := strings.Join(ss, ", ")
+		p.openScope()
+		list = append(list, &ast.DirectiveStmt{
+			At:      pos,
+			Prelude: &ast.BasicLit{ValuePos: pos, Kind: token.STRING, Value: sel},
+			Body:    p.parseBody(p.topScope),
+		})
+		p.closeScope()
+	}
+	p.sels = sels
+	rbrace := p.expect(token.RBRACE)
+	return &ast.BlockStmt{Lbrace: lbrace, List: list, Rbrace: rbrace}
+}
+
 func (p *parser) parseStmtList() []ast.Stmt {
 	if p.trace {
 		defer un(trace(p, "StatementList"))
@@ -1491,8 +1930,20 @@ func (p *parser) parseStmtList() []ast.Stmt {
 	var list []ast.Stmt
 	for p.tok != token.RBRACE && p.tok != token.EOF {
 		stmt, sel := p.parseStmt()
+		// messages of the functions stmt called stay in front of it
+		if _, ok := stmt.(*ast.AssignStmt); sel || ok && len(sels) > 0 {
+			sels = p.insertPending(sels, len(sels))
+		} else {
+			list = p.insertPending(list, len(list))
+		}
 		if sel {
 			sels = append(sels, stmt)
+		}
+		if p.media == 0 && len(p.escaped) > 0 {
+			sels = append(sels, p.escaped...)
+			p.escaped = nil
+		}
+		if sel {
 			continue
 		}
 		// TODO: for some damned reason, semicolons appear here
@@ -1500,6 +1951,12 @@ func (p *parser) parseStmtList() []ast.Stmt {
 		if _, ok := stmt.(*ast.EmptyStmt); ok {
 			continue
 		}
+		// Assignments stay behind the selectors before them, loop
+		// bodies are resolved again in this order
+		if _, ok := stmt.(*ast.AssignStmt); ok && len(sels) > 0 {
+			sels = append(sels, stmt)
+			continue
+		}
 		expand := p.unwrapInclude(stmt)
 		list = append(list, expand...)
 		if len(expand) > 0 {
@@ -1549,57 +2006,397 @@ func (p *parser) parseBlockStmt() *ast.BlockStmt {
 	return &ast.BlockStmt{Lbrace: lbrace, List: list, Rbrace: rbrace}
 }
 
+// @media screen and (min-width: $a), print { ... }
 func (p *parser) parseMediaStmt() *ast.MediaStmt {
 	if p.trace {
 		defer un(trace(p, "MediaStmt"))
 	}
 
 	pos := p.expect(token.MEDIA)
-	med := &ast.Ident{
-		NamePos: pos,
+	stmt := &ast.MediaStmt{
+		Name: &ast.Ident{NamePos: pos, Name: "@media"},
 	}
-	lit := &ast.BasicLit{
-		Kind:     token.STRING,
-		Value:    "@media " + p.lit,
-		ValuePos: p.pos,
+	stmt.Params = p.parseMediaParams()
+	if len(stmt.Params) == 0 {
+		p.errorExpected(p.pos, "media query")
 	}
-	p.expect(token.STRING)
+	// Mixin arguments are not known yet, the copy is resolved
+	if !p.inMixin {
+		p.resolveMediaQuery(stmt)
+	}
+	p.mediaSel(stmt)
+
+	queries := p.queries
+	p.queries = stmt.Queries
+	p.media++
+	stmt.Body = p.parseBody(p.topScope)
+	p.media--
+	p.queries = queries
+	return stmt
+}
 
-	return &ast.MediaStmt{
-		Name:  med,
-		Query: lit,
-		Body:  p.parseBody(p.topScope),
+// parseMediaParams parses the words, features and expressions of a
+// media query up to its block or the end of the statement
+func (p *parser) parseMediaParams() []ast.Expr {
+	var params []ast.Expr
+	for p.tok != token.LBRACE && p.tok != token.SEMICOLON &&
+		p.tok != token.EOF {
+		var x ast.Expr
+		switch p.tok {
+		case token.LPAREN:
+			x = p.parseMediaFeature()
+		case token.INTERP:
+			x = p.parseInterp()
+		case token.VAR:
+			x = p.parseOperand(false)
+		case token.STRING, token.COMMA:
+			x = &ast.BasicLit{
+				ValuePos: p.pos,
+				Kind:     token.STRING,
+				Value:    p.lit,
+			}
+			if p.tok == token.COMMA {
+				x.(*ast.BasicLit).Value = ","
+			}
+			p.next()
+		default:
+			p.errorExpected(p.pos, "media query")
+			p.next()
+			continue
+		}
+		params = append(params, x)
 	}
+	return params
 }
 
-// parseOperand may return an expression or a raw type (incl. array
-// types of the form [...]T. Callers must verify the result.
-// If lhs is set and the result is an identifier, it is not resolved.
-//
-func (p *parser) parseOperand(lhs bool) ast.Expr {
+// (min-width: $a + 1px)
+// (400px <= width <= 700px)
+func (p *parser) parseMediaFeature() *ast.MediaFeature {
 	if p.trace {
-		defer un(trace(p, "Operand"))
+		defer un(trace(p, "MediaFeature"))
 	}
 
+	f := &ast.MediaFeature{Lparen: p.expect(token.LPAREN)}
 	switch p.tok {
-	case token.IDENT:
-		x := p.parseIdent()
-		// token.LPAREN indicates call expr
-		if !lhs && p.tok != token.LPAREN {
-			p.resolve(x)
-		}
-		return x
 	case token.INTERP:
-		x := p.parseInterp()
-		p.resolveInterp(p.topScope, x)
-		return x
-	case token.QSTRING, token.QSSTRING:
-		// TODO: most definitely short sighed
-		pos, tok := p.pos, p.tok
-		p.next()
-		x := &ast.BasicLit{
-			Kind:     token.QSTRING,
-			Value:    p.lit,
+		f.Name = p.parseInterp()
+	case token.VAR:
+		f.Name = p.parseOperand(false)
+	default:
+		f.Name = &ast.BasicLit{
+			ValuePos: p.pos,
+			Kind:     token.STRING,
+			Value:    tokenText(p.tok, p.lit),
+		}
+		p.next()
+	}
+	switch p.tok {
+	case token.COLON:
+		p.next()
+		f.Value = p.inferExprList(false)
+	case token.RPAREN:
+	default:
+		// Ranges are read as they are
+		name, ok := f.Name.(*ast.BasicLit)
+		if !ok {
+			p.errorExpected(p.pos, "':'")
+			break
+		}
+		ss := []string{name.Value}
+		for p.tok != token.RPAREN && p.tok != token.LBRACE &&
+			p.tok != token.EOF {
+			ss = append(ss, tokenText(p.tok, p.lit))
+			p.next()
+		}
+		name.Value = strings.Join(ss, " ")
+	}
+	f.Rparen = p.expect(token.RPAREN)
+	return f
+}
+
+// tokenText returns the source text of operators and literals
+func tokenText(tok token.Token, lit string) string {
+	if len(lit) > 0 {
+		return lit
+	}
+	return tok.String()
+}
+
+// resolveMediaQuery resolves the query of stmt and merges it with the
+// queries of the enclosing @media
+func (p *parser) resolveMediaQuery(stmt *ast.MediaStmt) {
+	queries, ok := p.mediaQueries(stmt.Pos(), stmt.Params)
+	if !ok {
+		return
+	}
+	if p.queries != nil {
+		// a query that can not be written in CSS is not merged
+		if merged, ok := ast.MergeMediaQueries(p.queries, queries); ok {
+			queries = merged
+		}
+	}
+	stmt.Queries = queries
+	stmt.Query = &ast.BasicLit{
+		ValuePos: stmt.Pos(),
+		Kind:     token.STRING,
+		Value:    ast.MediaQueries(queries),
+	}
+}
+
+// mediaQueries resolves the parameters of a media query, errors are
+// reported at pos
+func (p *parser) mediaQueries(pos token.Pos, params []ast.Expr) ([]*ast.MediaQuery, bool) {
+	var ss []string
+	for _, x := range params {
+		var s string
+		switch v := x.(type) {
+		case *ast.BasicLit:
+			if v.Value == "," {
+				ss = append(ss, ",")
+				continue
+			}
+			s = v.Value
+		case *ast.MediaFeature:
+			name, err := p.mediaValue(v.Name)
+			if err != nil {
+				p.error(v.Pos(), err.Error())
+				return nil, false
+			}
+			s = "(" + name + ")"
+			if v.Value != nil {
+				val, err := p.mediaValue(v.Value)
+				if err != nil {
+					p.error(v.Value.Pos(), err.Error())
+					return nil, false
+				}
+				s = "(" + name + ": " + val + ")"
+			}
+		default:
+			val, err := p.mediaValue(v)
+			if err != nil {
+				p.error(v.Pos(), err.Error())
+				return nil, false
+			}
+			s = val
+		}
+		if len(ss) > 0 && ss[len(ss)-1] != "," {
+			s = " " + s
+		}
+		ss = append(ss, s)
+	}
+
+	queries, err := ast.ParseMediaQueries(strings.Join(ss, ""))
+	if err != nil {
+		p.error(pos, "Invalid CSS: "+err.Error())
+		return nil, false
+	}
+	return queries, true
+}
+
+// mediaValue resolves a part of a media query to its text
+func (p *parser) mediaValue(x ast.Expr) (string, error) {
+	if itp, ok := x.(*ast.Interp); ok {
+		p.resolveInterp(p.topScope, itp)
+		return itp.Obj.Decl.(*ast.BasicLit).Value, nil
+	}
+	return p.message(x)
+}
+
+// mediaSel records the selector @media was nested in, rules in its
+// body belong to it
+func (p *parser) mediaSel(stmt *ast.MediaStmt) {
+	stmt.Sel = nil
+	if len(p.sels) > 0 {
+		stmt.Sel = p.sels[len(p.sels)-1].Resolved
+	}
+}
+
+// @debug $x;
+// @error "invalid value #{$x}";
+func (p *parser) parseDebugStmt() *ast.DebugStmt {
+	if p.trace {
+		defer un(trace(p, "DebugStmt"))
+	}
+
+	pos, tok := p.pos, p.tok
+	p.next()
+	var x ast.Expr
+	if p.tok == token.SEMICOLON || p.tok == token.RBRACE {
+		p.errorExpected(p.pos, "expression")
+		x = &ast.BadExpr{From: pos, To: p.pos}
+	} else {
+		x = p.inferExprList(false)
+	}
+	p.expectSemi()
+	return &ast.DebugStmt{TokPos: pos, Tok: tok, X: x}
+}
+
+// message evaluates x to the text of @debug, @warn or @error. Strings
+// are unquoted.
+func (p *parser) message(x ast.Expr) (string, error) {
+	x, err := p.evalExpr(ast.ExprCopy(x))
+	if err != nil {
+		return "", err
+	}
+	list := []ast.Expr{x}
+	if s, ok := x.(*ast.StringExpr); ok {
+		list = s.List
+	}
+	ss := make([]string, 0, len(list))
+	for _, y := range list {
+		lit, err := calc.Resolve(y, true)
+		if err != nil {
+			return "", err
+		}
+		ss = append(ss, strops.Unquote(lit.Value))
+	}
+	return strings.Join(ss, " "), nil
+}
+
+// @at-root .sel { ... }
+// @at-root (without: media) { ... }
+func (p *parser) parseAtRootStmt() ast.Stmt {
+	if p.trace {
+		defer un(trace(p, "AtRootStmt"))
+	}
+
+	pos := p.expect(token.ATROOT)
+	stmt := &ast.AtRootStmt{AtRoot: pos}
+	if p.tok == token.LPAREN {
+		p.next()
+		switch p.lit {
+		case "with":
+			stmt.With = true
+		case "without":
+		default:
+			p.errorExpected(p.pos, `"with" or "without"`)
+		}
+		p.next()
+		p.expect(token.COLON)
+		for p.tok != token.RPAREN && p.tok != token.EOF {
+			stmt.Rules = append(stmt.Rules, strings.Fields(p.lit)...)
+			p.next()
+		}
+		p.expect(token.RPAREN)
+	}
+
+	// Rules directly in the body keep the selector when it is not
+	// excluded
+	if len(p.sels) > 0 && !stmt.Excludes("rule") {
+		stmt.Sel = p.sels[len(p.sels)-1].Resolved
+	}
+	lev, queries := p.rootLev, p.queries
+	if stmt.Excludes("rule") {
+		p.rootLev = len(p.sels) + 1
+	}
+	if stmt.Excludes("media") {
+		p.queries = nil
+	}
+	p.openScope()
+	if p.tok == token.LBRACE {
+		stmt.Body = p.parseBody(p.topScope)
+	} else {
+		sel := p.parseSelStmt(true)
+		stmt.Body = &ast.BlockStmt{
+			Lbrace: sel.Pos(),
+			List:   []ast.Stmt{sel},
+			Rbrace: sel.End() - 1,
+		}
+	}
+	p.closeScope()
+	p.rootLev, p.queries = lev, queries
+
+	if stmt.Sel == nil {
+		for _, s := range stmt.Body.List {
+			if isRuleStmt(s) {
+				p.error(s.Pos(), "Declarations may only be used within style rules.")
+				break
+			}
+		}
+	}
+	// @media is left by moving the statement after it
+	if p.media > 0 && stmt.Excludes("media") {
+		p.escaped = append(p.escaped, stmt)
+		return &ast.EmptyStmt{Semicolon: pos, Implicit: true}
+	}
+	return stmt
+}
+
+// isRuleStmt reports whether stmt is a property declaration ie. a: b;
+func isRuleStmt(stmt ast.Stmt) bool {
+	ds, ok := stmt.(*ast.DeclStmt)
+	if !ok {
+		return false
+	}
+	gen, ok := ds.Decl.(*ast.GenDecl)
+	if !ok || len(gen.Specs) == 0 {
+		return false
+	}
+	_, ok = gen.Specs[0].(*ast.RuleSpec)
+	return ok
+}
+
+// @extend .foo;
+// @extend %bar !optional;
+func (p *parser) parseExtendStmt() *ast.ExtendStmt {
+	if p.trace {
+		defer un(trace(p, "ExtendStmt"))
+	}
+
+	pos := p.expect(token.EXTEND)
+	stmt := &ast.ExtendStmt{Extend: pos}
+	sel := p.lit
+	if strings.HasSuffix(sel, "!optional") {
+		stmt.Optional = true
+		sel = strings.TrimSpace(strings.TrimSuffix(sel, "!optional"))
+	}
+	if len(sel) == 0 {
+		p.errorExpected(p.pos, "selector")
+	}
+	stmt.Sel = &ast.BasicLit{
+		Kind:     token.STRING,
+		Value:    sel,
+		ValuePos: p.pos,
+	}
+	p.expect(token.STRING)
+	p.expectSemi()
+	return stmt
+}
+
+// parseOperand may return an expression or a raw type (incl. array
+// types of the form [...]T. Callers must verify the result.
+// If lhs is set and the result is an identifier, it is not resolved.
+//
+func (p *parser) parseOperand(lhs bool) ast.Expr {
+	if p.trace {
+		defer un(trace(p, "Operand"))
+	}
+
+	switch p.tok {
+	case token.IDENT:
+		x := p.parseIdent()
+		// token.LPAREN indicates call expr
+		if !lhs && p.tok != token.LPAREN {
+			p.resolve(x)
+		}
+		return x
+	case token.INTERP:
+		x := p.parseInterp()
+		p.resolveInterp(p.topScope, x)
+		return x
+	case token.AND:
+		// & is the selector of the enclosing block, copies look it
+		// up again in resolveCall
+		x := &ast.UnaryExpr{OpPos: p.pos, Op: token.NEST, X: p.parentSel(p.pos)}
+		p.next()
+		return x
+	case token.QSTRING, token.QSSTRING:
+		// TODO: most definitely short sighed
+		pos, tok := p.pos, p.tok
+		p.next()
+		x := &ast.BasicLit{
+			Kind:     token.QSTRING,
+			Value:    p.lit,
 			ValuePos: pos,
 		}
 		p.next()
@@ -1609,17 +2406,12 @@ func (p *parser) parseOperand(lhs bool) ast.Expr {
 		token.COLOR,
 		token.UEM, token.UPCT, token.UPT, token.UPX, token.UREM,
 		token.INT, token.FLOAT, token.STRING:
-		x := &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit}
+		x := &ast.BasicLit{ValuePos: p.pos, Kind: litKind(p.tok, p.lit), Value: p.lit}
 		p.next()
 		return x
 
-	case token.LPAREN:
-		ls, _, _ := p.parseSassList(lhs, true)
-		if len(ls) > 1 {
-			panic("multiple lists found")
-		}
-
-		return ls[0]
+	case token.LPAREN, token.LBRACK:
+		return p.parseParenList(lhs)
 	case token.VAR:
 		// VAR is only hit while parsing function params, so
 		// this should only be allowed in that case.
@@ -1640,6 +2432,15 @@ func (p *parser) parseOperand(lhs bool) ast.Expr {
 	return &ast.BadExpr{From: pos, To: p.pos}
 }
 
+// litKind returns the kind of the literal lit scanned as tok, the word
+// null is the null value
+func litKind(tok token.Token, lit string) token.Token {
+	if tok == token.STRING && lit == "null" {
+		return token.NULL
+	}
+	return tok
+}
+
 func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
 	if p.trace {
 		defer un(trace(p, "IndexOrSlice"))
@@ -1697,20 +2498,19 @@ func (p *parser) parseCallOrConversion(fun ast.Expr) *ast.CallExpr {
 		p.error(fun.End(), "Functions are followed immediately by (")
 	}
 	pos := p.pos
-	lparen := p.expect(token.LPAREN)
-	p.exprLev++
-	var list []ast.Expr
-	expr := p.inferExprList(false)
-	lit, ok := expr.(*ast.ListLit)
-	if ok {
-		list = lit.Value
-	} else if expr != nil {
-		list = []ast.Expr{expr}
+	ident, ok := fun.(*ast.Ident)
+	if !ok {
+		p.error(fun.Pos(), "function name expected")
+		ident = ast.NewIdent("")
+	}
+	lazy := ident.Name == "if"
+	if lazy {
+		p.lazy++
+	}
+	lparen, list, rparen := p.parseArgs()
+	if lazy {
+		p.lazy--
 	}
-
-	p.exprLev--
-
-	rparen := p.expectClosing(token.RPAREN, "argument list")
 
 	call := &ast.CallExpr{
 		Fun:    fun,
@@ -1719,11 +2519,8 @@ func (p *parser) parseCallOrConversion(fun ast.Expr) *ast.CallExpr {
 		// Ellipsis: ellipsis,
 		Rparen: rparen,
 	}
-	ident, ok := fun.(*ast.Ident)
-	if !ok {
-		log.Fatalf("% #v\n", fun)
-	}
-	if p.mode&FuncOnly == 0 {
+	// Calls inside mixins are evaluated when included
+	if ok && p.mode&FuncOnly == 0 && !p.inMixin && p.lazy == 0 {
 		lit, err := evaluateCall(p, p.topScope, call)
 		call.Resolved = lit
 		// Manually set object, because Ident name isn't unique
@@ -1731,12 +2528,55 @@ func (p *parser) parseCallOrConversion(fun ast.Expr) *ast.CallExpr {
 		obj.Decl = lit
 		ident.Obj = obj
 		if err != nil {
-			p.error(pos, err.Error())
+			p.errorAt(pos, err)
 		}
 	}
 	return call
 }
 
+// parseArgs parses the arguments of a call or @include
+func (p *parser) parseArgs() (lparen token.Pos, list []ast.Expr, rparen token.Pos) {
+	if p.trace {
+		defer un(trace(p, "Args"))
+	}
+
+	lparen = p.expect(token.LPAREN)
+	p.exprLev++
+	// Arguments are parsed individually, so that a parenthesized
+	// first argument ie. map-get((a: b), a) isn't mistaken for
+	// parens wrapping all the arguments.
+	for p.tok != token.RPAREN && p.tok != token.EOF {
+		if x := p.listFromExprs(p.parseSassList(false, false)); x != nil {
+			list = append(list, keywordList(x))
+		}
+		if p.tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	p.exprLev--
+
+	rparen = p.expectClosing(token.RPAREN, "argument list")
+	return
+}
+
+// keywordList moves a space separated list following a keyword into
+// the keyword's value ie. $a: b c is a keyword with the value b c
+func keywordList(x ast.Expr) ast.Expr {
+	lit, ok := x.(*ast.ListLit)
+	if !ok || lit.Comma || len(lit.Value) < 2 {
+		return x
+	}
+	kv, ok := lit.Value[0].(*ast.KeyValueExpr)
+	if !ok {
+		return x
+	}
+	lit.Value[0] = kv.Value
+	lit.ValuePos = kv.Value.Pos()
+	kv.Value = lit
+	return kv
+}
+
 func (p *parser) parseValue(keyOk bool) ast.Expr {
 	if p.trace {
 		defer un(trace(p, "Element"))
@@ -1833,6 +2673,7 @@ func (p *parser) checkExpr(x ast.Expr) ast.Expr {
 	case *ast.Ident:
 	case *ast.BasicLit:
 	case *ast.ListLit:
+	case *ast.MapLit:
 	case *ast.FuncLit:
 	case *ast.Interp:
 	case *ast.StringExpr:
@@ -1962,7 +2803,7 @@ func (p *parser) parseUnaryExpr(lhs bool) ast.Expr {
 	}
 
 	switch p.tok {
-	case token.ADD, token.SUB, token.NOT, token.XOR, token.AND,
+	case token.ADD, token.SUB, token.NOT, token.XOR,
 		token.MUL, token.QUO:
 		pos, op := p.pos, p.tok
 		p.next()
@@ -1971,6 +2812,20 @@ func (p *parser) parseUnaryExpr(lhs bool) ast.Expr {
 		return un
 	case token.QSTRING, token.QSSTRING:
 		return p.parseString()
+	case token.STRING, token.IDENT:
+		if p.lit != "not" {
+			break
+		}
+		pos, tok := p.pos, p.tok
+		p.next()
+		switch p.tok {
+		case token.SEMICOLON, token.COMMA, token.LBRACE, token.RBRACE,
+			token.RPAREN, token.EOF:
+			// not without an operand is a plain string
+			return &ast.BasicLit{ValuePos: pos, Kind: tok, Value: "not"}
+		}
+		x := p.parseUnaryExpr(false)
+		return &ast.UnaryExpr{OpPos: pos, Op: token.NOT, X: p.checkExpr(x)}
 	}
 
 	return p.parsePrimaryExpr(lhs)
@@ -1981,6 +2836,15 @@ func (p *parser) tokPrec() (token.Token, int) {
 	if p.inRhs && tok == token.ASSIGN {
 		tok = token.EQL
 	}
+	if tok == token.STRING || tok == token.IDENT {
+		// boolean operators are words in Sass
+		switch p.lit {
+		case "and":
+			tok = token.LAND
+		case "or":
+			tok = token.LOR
+		}
+	}
 	return tok, tok.Precedence()
 }
 
@@ -1997,7 +2861,9 @@ func (p *parser) parseBinaryExpr(lhs bool, inParens bool, prec1 int) ast.Expr {
 			if oprec != prec {
 				break
 			}
-			pos := p.expect(op)
+			// op may differ from p.tok ie. and
+			pos := p.pos
+			p.next()
 			if lhs {
 				p.resolve(x)
 				lhs = false
@@ -2117,7 +2983,11 @@ func (p *parser) parseReturnStmt() *ast.ReturnStmt {
 	p.expect(token.RETURN)
 	var x []ast.Expr
 	if p.tok != token.SEMICOLON && p.tok != token.RBRACE {
-		x = p.parseRhsList()
+		// the value is a list like the value of an assignment
+		old := p.inRhs
+		p.inRhs = true
+		x = []ast.Expr{p.inferExprList(false)}
+		p.inRhs = old
 	}
 	p.expectSemi()
 
@@ -2175,34 +3045,19 @@ func (p *parser) parseIfStmt() *ast.IfStmt {
 }
 
 func (p *parser) resolveIfStmt(scope *ast.Scope, in *ast.IfStmt) []ast.Stmt {
-	fmt.Println("resolve ifstmt!")
 	var ret []ast.Stmt
 	decl := in
 	cond := in.Cond
 	// TODO: This is weird, but it resolves idents
 	p.resolveExpr(scope, cond)
 
-	// Check if this points to a binary expr, otherwise
-	// compare with false (and maybe nil?)
-	var compFalse bool
-	if ident, ok := cond.(*ast.Ident); ok {
-		if _, ok := ident.Obj.Decl.(*ast.BinaryExpr); !ok {
-			compFalse = true
-		}
-	}
-
-	fmt.Printf("cond % #v\n", decl.Cond)
 	lit, err := calc.Resolve(decl.Cond, true)
 	if err != nil {
 		panic(fmt.Sprint("failed to understand condition: ", err))
 	}
 	switch {
-	// This checks just for presence of a variable
-	case compFalse && lit.Value != "false":
-		fmt.Println("compfalse", lit.Value)
-		fallthrough
-	case lit.Value == "true":
-		fmt.Println("true...")
+	// only false and null are falsy
+	case calc.Truthy(lit):
 		resList := p.resolveStmts(scope, decl.Body.List)
 		ret = append(ret, resList...)
 	default:
@@ -2235,6 +3090,7 @@ func (p *parser) parseTypeList() (list []ast.Expr) {
 
 // @each $i in (1 2 3)
 // @each $i in a b c
+// @each $k, $v in $map
 func (p *parser) parseEachStmt() *ast.EachStmt {
 	if p.trace {
 		defer un(trace(p, "EachStmt"))
@@ -2243,6 +3099,11 @@ func (p *parser) parseEachStmt() *ast.EachStmt {
 	pos := p.expect(token.EACH)
 	// each variable iterator
 	itr := p.parseVarType(true).(*ast.Ident)
+	var vars []*ast.Ident
+	for p.tok == token.COMMA {
+		p.next()
+		vars = append(vars, p.parseVarType(true).(*ast.Ident))
+	}
 
 	// in
 	if p.lit != "in" {
@@ -2251,149 +3112,408 @@ func (p *parser) parseEachStmt() *ast.EachStmt {
 		p.next()
 	}
 
-	list, _, _ := p.parseSassList(true, false)
+	list, hasComma, inParen := p.parseSassList(true, false)
+	if p.tok == token.COMMA {
+		// comma separated, every element may be a list
+		list = []ast.Expr{p.listFromExprs(list, hasComma, inParen)}
+		for p.tok == token.COMMA {
+			p.next()
+			list = append(list, p.listFromExprs(p.parseSassList(true, false)))
+		}
+	}
+	itrs := append([]*ast.Ident{itr}, vars...)
 
+	// Like @for, declare the iterators with the first value so the
+	// body can be evaluated while parsing.
+	p.openScope()
+	if !p.inMixin {
+		if l := p.expandList(list); len(l) > 0 {
+			p.assignEach(p.topScope, itrs, l[0])
+		}
+	}
+	p.loops++
 	body := p.parseBody(p.topScope)
+	p.loops--
+	p.closeScope()
+
 	each := &ast.EachStmt{
 		Each: pos,
 		X:    itr,
+		Vars: vars,
 		List: list,
 		Body: body,
 	}
 
-	// FIXME: decide when to resolve the each stmt
-	if !p.inMixin {
+	// loops inside of loops are unrolled by the outermost one
+	if !p.inMixin && p.loops == 0 {
 		p.resolveEachStmt(p.topScope, each)
 	}
 	return each
 }
 
 func (p *parser) resolveEachStmt(outscope *ast.Scope, each *ast.EachStmt) {
-	litsToExprs := func(in []*ast.BasicLit) []ast.Expr {
-		out := make([]ast.Expr, len(in))
-		for i := range in {
-			out[i] = in[i]
-		}
-		return out
-	}
-
 	// attempt expansion of $var in $vars
 	list := p.expandList(each.List)
-	itrName := each.X.Name
-	r := ast.NewIdent(itrName)
-
-	// at some point, all decl are enforced as AssignStmt
-	ass := &ast.AssignStmt{
-		Lhs:    []ast.Expr{r},
-		TokPos: list[0].Pos(),
-		Rhs:    litsToExprs(p.resolveExpr(outscope, list[0])),
-	}
+	itrs := append([]*ast.Ident{each.X}, each.Vars...)
 
 	var stmts []ast.Stmt
-	// walk through first iterator
-	scope := ast.NewScope(outscope)
-	p.declare(ass, nil, scope, ast.Var, r)
-	copy := make([]ast.Stmt, len(each.Body.List))
-	for i := range each.Body.List {
-		copy[i] = ast.StmtCopy(each.Body.List[i])
-	}
-	stmts = append(stmts, p.resolveStmts(scope, copy)...)
-
-	for _, l := range list[1:] {
-		// Copy the body from list 1
+	for _, l := range list {
+		// Copy the body for every value
 		copy := make([]ast.Stmt, len(each.Body.List))
 		for i := range each.Body.List {
 			copy[i] = ast.StmtCopy(each.Body.List[i])
 		}
 
-		r := ast.NewIdent(itrName)
+		scope := ast.NewScope(outscope)
+		p.assignEach(scope, itrs, l)
+		stmts = append(stmts, p.resolveStmts(scope, copy)...)
+		assignOuter(scope, outscope, itrs...)
+	}
+	// Modify body with new stmts
+	each.Body.List = stmts
+}
+
+// assignEach declares the iterators of @each in scope with the value x
+func (p *parser) assignEach(scope *ast.Scope, itrs []*ast.Ident, x ast.Expr) {
+	vals := p.destructure(x, len(itrs))
+	for i, itr := range itrs {
+		rhs := p.resolveValue(scope.Outer, vals[i])
+		r := ast.NewIdent(itr.Name)
 		// at some point, all decl are enforced as AssignStmt
 		ass := &ast.AssignStmt{
 			Lhs:    []ast.Expr{r},
-			TokPos: list[0].Pos(),
-			Rhs:    litsToExprs(p.resolveExpr(scope, l)),
+			TokPos: x.Pos(),
+			Rhs:    rhs,
 		}
-		scope := ast.NewScope(outscope)
 		p.declare(ass, nil, scope, ast.Var, r)
-		stmts = append(stmts, p.resolveStmts(scope, copy)...)
 	}
-	// Modify body with new stmts
-	each.Body.List = stmts
 }
 
-func (p *parser) parseForStmt() ast.Stmt {
+// resolveValue resolves x in scope, lists keep their structure so
+// nested lists remain a single value
+func (p *parser) resolveValue(scope *ast.Scope, x ast.Expr) []ast.Expr {
+	list, ok := x.(*ast.ListLit)
+	if !ok {
+		lits := p.resolveExpr(scope, x)
+		out := make([]ast.Expr, len(lits))
+		for i := range lits {
+			out[i] = lits[i]
+		}
+		return out
+	}
+	l := *list
+	l.Value = make([]ast.Expr, 0, len(list.Value))
+	for _, y := range list.Value {
+		l.Value = append(l.Value, p.resolveValue(scope, y)...)
+	}
+	return []ast.Expr{&l}
+}
+
+// destructure splits x into n values for the iterators of @each.
+// A single iterator receives x, otherwise x is treated as a list and
+// iterators past the end of it are null.
+func (p *parser) destructure(x ast.Expr, n int) []ast.Expr {
+	if n == 1 {
+		return []ast.Expr{x}
+	}
+	items := p.expandList([]ast.Expr{x})
+	if list, ok := x.(*ast.ListLit); ok {
+		items = list.Value
+	}
+	vals := make([]ast.Expr, n)
+	copy(vals, items)
+	for i := range vals {
+		if vals[i] == nil {
+			vals[i] = &ast.BasicLit{
+				ValuePos: x.Pos(),
+				Kind:     token.NULL,
+				Value:    "null",
+			}
+		}
+	}
+	return vals
+}
+
+// @for $i from 1 through 3 { ... }
+// @for $i from 1 to 3 { ... }
+func (p *parser) parseForStmt() *ast.ForStmt {
 	if p.trace {
 		defer un(trace(p, "ForStmt"))
 	}
 
 	pos := p.expect(token.FOR)
-	p.openScope()
-	defer p.closeScope()
+	itr := p.parseVarType(true).(*ast.Ident)
 
-	var s1, s2, s3 ast.Stmt
-	var isRange bool
-	if p.tok != token.LBRACE {
+	if p.lit != "from" {
+		p.errorExpected(p.pos, "from after iterator ie @for $i from")
+	} else {
+		p.next()
+	}
+	from := p.inferExpr(false, false)
+
+	var through bool
+	switch p.lit {
+	case "through":
+		through = true
+		p.next()
+	case "to":
+		p.next()
+	default:
+		p.errorExpected(p.pos, "through or to ie @for $i from 1 through 3")
+	}
+	to := p.inferExpr(false, false)
+
+	// The body is resolved again for every iteration, but values
+	// depending on the iterator are evaluated while parsing. Declare
+	// it with the first value of the range.
+	p.openScope()
+	if !p.inMixin {
+		if lit, err := p.resolveBound(from); err == nil {
+			r := ast.NewIdent(itr.Name)
+			ass := &ast.AssignStmt{
+				Lhs:    []ast.Expr{r},
+				TokPos: itr.Pos(),
+				Rhs:    []ast.Expr{lit},
+			}
+			p.declare(ass, nil, p.topScope, ast.Var, r)
+		}
+	}
+	p.loops++
+	body := p.parseBody(p.topScope)
+	p.loops--
+	p.closeScope()
+
+	stmt := &ast.ForStmt{
+		For:     pos,
+		X:       itr,
+		From:    from,
+		To:      to,
+		Through: through,
+		Body:    body,
+	}
+	if !p.inMixin && p.loops == 0 {
+		p.resolveForStmt(p.topScope, stmt)
+	}
+	return stmt
+}
+
+// forRange returns the values the iterator of stmt takes. Errors are
+// reported with the position of the offending bound.
+func (p *parser) forRange(stmt *ast.ForStmt) ([]*ast.BasicLit, token.Pos, error) {
+	from, err := p.resolveBound(stmt.From)
+	if err != nil {
+		return nil, stmt.From.Pos(), err
+	}
+	to, err := p.resolveBound(stmt.To)
+	if err != nil {
+		return nil, stmt.To.Pos(), err
+	}
+	start, unit, err := splitNumber(from)
+	if err != nil {
+		return nil, stmt.From.Pos(), err
+	}
+	end, toUnit, err := splitNumber(to)
+	if err != nil {
+		return nil, stmt.To.Pos(), err
+	}
+	kind := from.Kind
+	if len(unit) == 0 {
+		unit, kind = toUnit, to.Kind
+	} else if len(toUnit) > 0 && unit != toUnit {
+		return nil, stmt.To.Pos(), fmt.Errorf(
+			"Incompatible units: '%s' and '%s'.", toUnit, unit)
+	}
+
+	step := 1
+	if start > end {
+		step = -1
+	}
+	if stmt.Through {
+		end += step
+	}
+
+	var values []*ast.BasicLit
+	for i := start; i != end; i += step {
+		values = append(values, &ast.BasicLit{
+			ValuePos: stmt.X.Pos(),
+			Kind:     kind,
+			Value:    strconv.Itoa(i) + unit,
+		})
+	}
+	return values, token.NoPos, nil
+}
+
+// resolveBound evaluates the from and to expressions of @for
+func (p *parser) resolveBound(x ast.Expr) (*ast.BasicLit, error) {
+	if call, ok := x.(*ast.CallExpr); !ok || call.Resolved == nil {
+		var err error
+		x, err = p.resolveCall(x)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return calc.Resolve(x, true)
+}
+
+// splitNumber separates a number from its unit, @for bounds must be
+// integers.
+func splitNumber(lit *ast.BasicLit) (int, string, error) {
+	i := 0
+	if i < len(lit.Value) && (lit.Value[i] == '-' || lit.Value[i] == '+') {
+		i++
+	}
+	for i < len(lit.Value) && (isDigit(lit.Value[i]) || lit.Value[i] == '.') {
+		i++
+	}
+	f, err := strconv.ParseFloat(lit.Value[:i], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s is not a number", lit.Value)
+	}
+	if f != math.Trunc(f) {
+		return 0, "", fmt.Errorf("%s is not an integer", lit.Value)
+	}
+	return int(f), lit.Value[i:], nil
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// resolveForStmt replaces the body of the @for with a copy of the
+// body for every iteration. Each iteration has a fresh scope where
+// the iterator is declared.
+func (p *parser) resolveForStmt(outscope *ast.Scope, stmt *ast.ForStmt) {
+	oldScope := p.topScope
+	p.topScope = outscope
+	defer func() { p.topScope = oldScope }()
+
+	values, pos, err := p.forRange(stmt)
+	if err != nil {
+		p.error(pos, err.Error())
+		return
+	}
+
+	var stmts []ast.Stmt
+	for _, lit := range values {
+		copy := make([]ast.Stmt, len(stmt.Body.List))
+		for j := range stmt.Body.List {
+			copy[j] = ast.StmtCopy(stmt.Body.List[j])
+		}
+
+		r := ast.NewIdent(stmt.X.Name)
+		// at some point, all decl are enforced as AssignStmt
+		ass := &ast.AssignStmt{
+			Lhs:    []ast.Expr{r},
+			TokPos: stmt.X.Pos(),
+			Rhs:    []ast.Expr{lit},
+		}
+		scope := ast.NewScope(outscope)
+		p.declare(ass, nil, scope, ast.Var, r)
+		stmts = append(stmts, p.resolveStmts(scope, copy)...)
+		assignOuter(scope, outscope, stmt.X)
+	}
+	// Modify body with new stmts
+	stmt.Body.List = stmts
+}
+
+func (p *parser) parseWhileStmt() *ast.WhileStmt {
+	if p.trace {
+		defer un(trace(p, "WhileStmt"))
+	}
+
+	pos := p.expect(token.WHILE)
+	var cond ast.Expr
+	{
 		prevLev := p.exprLev
 		p.exprLev = -1
-		if p.tok != token.SEMICOLON {
-			s2, isRange = p.parseSimpleStmt(rangeOk)
+		cond = p.parseRhs()
+		p.exprLev = prevLev
+	}
+
+	// Like @for, the body is parsed with the values found before
+	// the loop and resolved again for every iteration.
+	p.openScope()
+	p.loops++
+	body := p.parseBody(p.topScope)
+	p.loops--
+	p.closeScope()
+
+	stmt := &ast.WhileStmt{
+		While: pos,
+		Cond:  cond,
+		Body:  body,
+	}
+	if !p.inMixin && p.loops == 0 {
+		p.resolveWhileStmt(p.topScope, stmt)
+	}
+	return stmt
+}
+
+// resolveWhileStmt unrolls the body of @while until the condition is
+// false. Unlike @for, assignments in the body update variables of the
+// enclosing scopes, otherwise the condition could never change.
+func (p *parser) resolveWhileStmt(outscope *ast.Scope, stmt *ast.WhileStmt) {
+	oldScope := p.topScope
+	p.topScope = outscope
+	defer func() { p.topScope = oldScope }()
 
+	var stmts []ast.Stmt
+	for n := 0; ; n++ {
+		// idents in the copy are resolved against the current values
+		cond := ast.ExprCopy(stmt.Cond)
+		p.resolveExpr(outscope, cond)
+		lit, err := calc.Resolve(cond, true)
+		if err != nil {
+			p.error(stmt.Cond.Pos(), err.Error())
+			return
 		}
-		if !isRange && p.tok == token.SEMICOLON {
-			p.next()
-			s1 = s2
-			s2 = nil
-			if p.tok != token.SEMICOLON {
-				s2, _ = p.parseSimpleStmt(basic)
-			}
-			p.expectSemi()
-			if p.tok != token.LBRACE {
-				s3, _ = p.parseSimpleStmt(basic)
-			}
+		if !calc.Truthy(lit) {
+			break
 		}
-		p.exprLev = prevLev
+		if n == p.maxIterations {
+			p.error(stmt.Pos(), fmt.Sprintf("@while exceeded %d iterations",
+				p.maxIterations))
+			return
+		}
+
+		copy := make([]ast.Stmt, len(stmt.Body.List))
+		for j := range stmt.Body.List {
+			copy[j] = ast.StmtCopy(stmt.Body.List[j])
+		}
+		scope := ast.NewScope(outscope)
+		stmts = append(stmts, p.resolveStmts(scope, copy)...)
+		assignOuter(scope, outscope)
 	}
+	// Modify body with new stmts
+	stmt.Body.List = stmts
+}
 
-	body := p.parseBlockStmt()
-	p.expectSemi()
+// assignOuter updates the variables of the scopes enclosing a loop
+// that were assigned in an iteration's scope. Like Sass, assignments
+// in loops don't shadow existing variables. The iterators of the loop
+// stay local.
+func assignOuter(scope, outscope *ast.Scope, itrs ...*ast.Ident) {
+	for name, obj := range scope.Objects {
+		if isIterator(name, itrs) {
+			continue
+		}
+		for s := outscope; s != nil; s = s.Outer {
+			if s.Lookup(name) != nil {
+				s.Objects[name] = obj
+				break
+			}
+		}
+	}
+}
 
-	if isRange {
-		as := s2.(*ast.AssignStmt)
-		// check lhs
-		var key, value ast.Expr
-		switch len(as.Lhs) {
-		case 0:
-			// nothing to do
-		case 1:
-			key = as.Lhs[0]
-		case 2:
-			key, value = as.Lhs[0], as.Lhs[1]
-		default:
-			p.errorExpected(as.Lhs[len(as.Lhs)-1].Pos(), "at most 2 expressions")
-			return &ast.BadStmt{From: pos, To: p.safePos(body.End())}
-		}
-		// parseSimpleStmt returned a right-hand side that
-		// is a single unary expression of the form "range x"
-		x := as.Rhs[0].(*ast.UnaryExpr).X
-		return &ast.RangeStmt{
-			For:    pos,
-			Key:    key,
-			Value:  value,
-			TokPos: as.TokPos,
-			Tok:    as.Tok,
-			X:      x,
-			Body:   body,
-		}
-	}
-
-	// regular for statement
-	return &ast.ForStmt{
-		For:  pos,
-		Init: s1,
-		Cond: p.makeExpr(s2, "boolean or range expression"),
-		Post: s3,
-		Body: body,
+// isIterator reports whether name is one of itrs
+func isIterator(name string, itrs []*ast.Ident) bool {
+	for _, itr := range itrs {
+		if itr.Name == name {
+			return true
+		}
 	}
+	return false
 }
 
 func (p *parser) parseStmt() (s ast.Stmt, isSelector bool) {
@@ -2428,7 +3548,7 @@ func (p *parser) parseStmt() (s ast.Stmt, isSelector bool) {
 	case
 		// TODO: Not sure any of these cases ever exist in Sass
 		// tokens that may start an expression
-		token.INT, token.FLOAT, token.STRING, token.FUNC, token.LPAREN, // operands
+		token.INT, token.FLOAT, token.STRING, token.LPAREN, // operands
 		token.LBRACK,
 		// composite types
 		token.GTR, token.TIL,
@@ -2446,6 +3566,17 @@ func (p *parser) parseStmt() (s ast.Stmt, isSelector bool) {
 		s = p.parseReturnStmt()
 	case token.MEDIA:
 		s = p.parseMediaStmt()
+		isSelector = true
+	case token.EXTEND:
+		s = p.parseExtendStmt()
+	case token.DEBUG, token.WARN, token.ERROR:
+		s = p.parseDebugStmt()
+	case token.DIRECTIVE:
+		stmt := p.parseDirectiveStmt()
+		s, isSelector = stmt, stmt.Body != nil
+	case token.ATROOT:
+		s = p.parseAtRootStmt()
+		_, isSelector = s.(*ast.AtRootStmt)
 	case token.LBRACE:
 		s = p.parseBlockStmt()
 		p.expectSemi()
@@ -2453,8 +3584,13 @@ func (p *parser) parseStmt() (s ast.Stmt, isSelector bool) {
 		s = p.parseIfStmt()
 	case token.FOR:
 		s = p.parseForStmt()
+	case token.WHILE:
+		s = p.parseWhileStmt()
 	case token.IMPORT:
-		s = &ast.DeclStmt{Decl: p.parseGenDecl("", token.IMPORT, p.parseImportSpec)}
+		s = &ast.DeclStmt{Decl: p.parseImportDecl()}
+	case token.MIXIN, token.FUNC:
+		// declared by nested imports
+		s = &ast.DeclStmt{Decl: p.parseDecl(syncStmt)}
 	case token.INCLUDE:
 		s = &ast.IncludeStmt{Spec: p.parseIncludeSpec(!p.inMixin)}
 	case token.SELECTOR:
@@ -2496,45 +3632,165 @@ func isValidImport(lit string) bool {
 	return s != ""
 }
 
-func (p *parser) parseImportSpec(doc *ast.CommentGroup, _ token.Token, _ int) ast.Spec {
+// @import "a", "b.css", url(c) screen;
+func (p *parser) parseImportDecl() *ast.GenDecl {
 	if p.trace {
-		// defer un(trace(p, "ImportSpec"))
+		defer un(trace(p, "ImportDecl"))
 	}
 
-	var ident *ast.Ident
-	switch p.tok {
-	case token.PERIOD:
-		ident = &ast.Ident{NamePos: p.pos, Name: "."}
+	pos := p.expect(token.IMPORT)
+	var specs []*ast.ImportSpec
+	for {
+		specs = append(specs, p.parseImportSpec())
+		if p.tok != token.COMMA {
+			break
+		}
 		p.next()
-	case token.IDENT:
-		ident = p.parseIdent()
+	}
+	// a media query belongs to the last import
+	if p.tok != token.SEMICOLON && p.tok != token.RBRACE &&
+		p.tok != token.EOF {
+		spec := specs[len(specs)-1]
+		spec.Plain = true
+		queries, ok := p.mediaQueries(p.pos, p.parseMediaParams())
+		if ok {
+			spec.Media = &ast.BasicLit{
+				ValuePos: spec.Path.End(),
+				Kind:     token.STRING,
+				Value:    ast.MediaQueries(queries),
+			}
+		}
 	}
 
-	p.expect(token.IMPORT)
-	x := p.parseOperand(false)
-	pathlit, ok := x.(*ast.BasicLit)
-	if !ok {
-		p.errorExpected(x.Pos(), "expected import to be string or quoted string")
+	list := make([]ast.Spec, len(specs))
+	for i, spec := range specs {
+		list[i] = spec
+		if spec.Plain {
+			continue
+		}
+		// Sass imports are inlined after the statement
+		if err := p.processImport(spec.Pos(), spec.Path.Value); err != nil {
+			p.error(spec.Pos(), "failed to import: "+err.Error())
+		}
 	}
+	p.expectSemi()
 
-	// collect imports
-	spec := &ast.ImportSpec{
-		// Doc:     doc,
-		Name:    ident,
-		Path:    pathlit,
-		Comment: p.lineComment,
+	return &ast.GenDecl{
+		TokPos: pos,
+		Tok:    token.IMPORT,
+		Specs:  list,
 	}
-	// Parse and insert the results into the current parser
-	p.imports = append(p.imports, spec)
-	err := p.processImport(spec.Path.Value)
-	if err != nil {
-		log.Fatalf("failed to import: %s", spec.Name)
+}
+
+func (p *parser) parseImportSpec() *ast.ImportSpec {
+	if p.trace {
+		defer un(trace(p, "ImportSpec"))
+	}
+
+	spec := &ast.ImportSpec{Comment: p.lineComment}
+	switch {
+	case p.tok == token.STRING && strings.HasPrefix(p.lit, "url("):
+		spec.Path = &ast.BasicLit{
+			ValuePos: p.pos,
+			Kind:     token.STRING,
+			Value:    p.lit,
+		}
+		p.next()
+	case p.tok == token.QSTRING || p.tok == token.QSSTRING:
+		// interpolated paths are plain CSS imports
+		spec.Path, spec.Plain = p.parseImportPath()
+	default:
+		x := p.parseOperand(false)
+		lit, ok := x.(*ast.BasicLit)
+		if !ok {
+			p.errorExpected(x.Pos(), "import to be string or quoted string")
+			lit = &ast.BasicLit{ValuePos: x.Pos(), Kind: token.STRING}
+		}
+		spec.Path = lit
 	}
+	spec.Plain = spec.Plain || plainImport(spec.Path.Value)
+	p.imports = append(p.imports, spec)
 	return spec
 }
 
-func (p *parser) processImport(path string) error {
-	return p.add(path, nil)
+// parseImportPath parses a quoted import path. The scanner splits it
+// into tokens ie. "/" and "x/y", they are joined back into the text of
+// the path. interp reports whether the path is interpolated.
+func (p *parser) parseImportPath() (path *ast.BasicLit, interp bool) {
+	quote := p.tok
+	path = &ast.BasicLit{ValuePos: p.pos, Kind: token.QSTRING}
+	p.next()
+	var ss []string
+	end := p.pos
+	for p.tok != token.EOF && p.tok != quote {
+		// the text between tokens ie. spaces
+		if gap := int(p.pos - end); gap > 0 && len(ss) > 0 {
+			ss = append(ss, strings.Repeat(" ", gap))
+		}
+		if p.tok == token.INTERP {
+			itp := p.parseInterp()
+			p.resolveInterp(p.topScope, itp)
+			if itp.Obj != nil {
+				ss = append(ss, itp.Obj.Decl.(*ast.BasicLit).Value)
+			}
+			interp, end = true, itp.End()
+			continue
+		}
+		s := p.lit
+		if s == "" {
+			s = p.tok.String()
+		}
+		ss = append(ss, s)
+		end = p.pos + token.Pos(len(s))
+		p.next()
+	}
+	p.expectClosing(quote, "import path")
+	path.Value = strings.Join(ss, "")
+	return path, interp
+}
+
+// plainImport reports whether path is left to the browser instead of
+// being inlined ie. foo.css, http://foo or url(foo)
+func plainImport(path string) bool {
+	return strings.HasSuffix(path, ".css") ||
+		strings.HasPrefix(path, "http://") ||
+		strings.HasPrefix(path, "https://") ||
+		strings.HasPrefix(path, "//") ||
+		strings.HasPrefix(path, "url(")
+}
+
+func (p *parser) processImport(pos token.Pos, path string) error {
+	if isGlob(path) {
+		return p.addGlob(pos, path)
+	}
+	return p.add(pos, path)
+}
+
+// addGlob imports the files matching pattern, as if they were listed
+// in sorted order
+func (p *parser) addGlob(pos token.Pos, pattern string) error {
+	g, ok := p.importer.(GlobImporter)
+	if !ok {
+		return fmt.Errorf("importer does not support glob imports: %s", pattern)
+	}
+	names, match, err := g.Glob(pattern, p.file.Name())
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("file to import not found: %s", pattern)
+	}
+	p.globs = append(p.globs, match)
+	for _, name := range names {
+		// a file never matches itself
+		if name == p.file.Name() {
+			continue
+		}
+		if err := p.enqueue(&queue{filename: name, pos: pos}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (p *parser) inferSelSpec(doc *ast.CommentGroup, keyword token.Token, iota int) ast.Spec {
@@ -2548,29 +3804,38 @@ func (p *parser) inferSelSpec(doc *ast.CommentGroup, keyword token.Token, iota i
 	}
 }
 
-// checkForGlobal inspects variable declarations looking for the
-// !global keyword
-func checkForGlobal(vals []ast.Expr) bool {
-	if len(vals) == 0 {
-		return false
+// varFlags removes !global and !default from the end of a variable
+// declaration, recording them on name
+func varFlags(name *ast.Ident, vals []ast.Expr) []ast.Expr {
+	if len(vals) == 1 {
+		list, ok := vals[0].(*ast.ListLit)
+		if !ok {
+			return vals
+		}
+		list.Value = varFlags(name, list.Value)
+		// (a,) and [a] are lists of one value
+		if len(list.Value) == 1 && !list.Comma && !list.Bracket {
+			return list.Value
+		}
+		return vals
 	}
 
-	if len(vals) < 2 {
-		if list, ok := vals[0].(*ast.ListLit); ok {
-			vals = list.Value
-		} else {
-			return false
+	for len(vals) > 1 {
+		lit, ok := vals[len(vals)-1].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			break
 		}
+		switch lit.Value {
+		case "!global":
+			name.Global = true
+		case "!default":
+			name.Default = true
+		default:
+			return vals
+		}
+		vals = vals[:len(vals)-1]
 	}
-	lit, ok := vals[len(vals)-1].(*ast.BasicLit)
-	if !ok {
-		return false
-	}
-	if lit.Kind == token.STRING && lit.Value == "!global" {
-		return true
-	}
-
-	return false
+	return vals
 }
 
 func (p *parser) inferValueSpec(doc *ast.CommentGroup, keyword token.Token, iota int) ast.Spec {
@@ -2640,7 +3905,7 @@ func (p *parser) inferValueSpec(doc *ast.CommentGroup, keyword token.Token, iota
 
 	switch keyword {
 	case token.VAR:
-		name.Global = checkForGlobal(values)
+		values = varFlags(name, values)
 		// Assignment happening
 		spec = &ast.ValueSpec{
 			// Doc:   doc,
@@ -2710,12 +3975,48 @@ func (p *parser) closeSelector() {
 	p.sels = p.sels[:len(p.sels)-1]
 }
 
+// selParent returns the selector that sel is nested in. Directly inside
+// of @at-root, only selectors referencing their parent with & have one.
+func (p *parser) selParent(sel ast.Expr) *ast.SelStmt {
+	if len(p.sels) == 0 {
+		return nil
+	}
+	if p.rootLev == len(p.sels)+1 && !hasBackref(sel) {
+		return nil
+	}
+	return p.sels[len(p.sels)-1]
+}
+
+// parentSel returns the value of & in expressions
+func (p *parser) parentSel(pos token.Pos) *ast.BasicLit {
+	lit := &ast.BasicLit{ValuePos: pos, Kind: token.NULL, Value: "null"}
+	if len(p.sels) > 0 {
+		lit.Kind = token.STRING
+		lit.Value = p.sels[len(p.sels)-1].Resolved.Value
+	}
+	return lit
+}
+
+// hasBackref reports whether the selector contains &
+func hasBackref(sel ast.Expr) bool {
+	switch v := sel.(type) {
+	case *ast.UnaryExpr:
+		return v.Op == token.NEST || hasBackref(v.X)
+	case *ast.BinaryExpr:
+		return hasBackref(v.X) || hasBackref(v.Y)
+	}
+	return false
+}
+
 func (p *parser) parseSelStmt(backrefOk bool) *ast.SelStmt {
 	if p.trace {
 		defer un(trace(p, "SelStmt"))
 	}
-	lit := p.lit
-	pos := p.expect(token.SELECTOR)
+	lit, pos := p.lit, p.pos
+	// selectors starting with interpolation have no SELECTOR token
+	if p.tok != token.INTERP {
+		pos = p.expect(token.SELECTOR)
+	}
 	assert(pos != 0, "invalid selector position")
 	scope := ast.NewScope(p.topScope)
 	// idents := p.processSelectors(scope, lit, pos, backrefOk)
@@ -2726,10 +4027,6 @@ func (p *parser) parseSelStmt(backrefOk bool) *ast.SelStmt {
 		},
 	}
 
-	if len(p.sels) > 0 {
-		sel.Parent = p.sels[len(p.sels)-1]
-	}
-
 	var xs []ast.Expr
 	for p.tok != token.LBRACE {
 		x := p.parseCombSel(token.LowestPrec + 1)
@@ -2745,15 +4042,13 @@ func (p *parser) parseSelStmt(backrefOk bool) *ast.SelStmt {
 		return sel
 	}
 	sel.Sel = xs[0]
+	sel.Parent = p.selParent(sel.Sel)
 	s, ok := itpMerge(xs)
 	if ok {
-		fmt.Println("itpMerge", s)
-		stmt, err := reparseSelector(s)
-		if err != nil {
-			p.error(pos, err.Error())
-		}
-		sel.Sel = stmt.Sel
-		sel.Resolved = stmt.Resolved
+		// Preserve interpolation, copies of this selector ie. in
+		// @for will need to resolve it again
+		sel.Raw = xs
+		p.mergeSelector(sel, s)
 	}
 	sel.Resolve(Globalfset)
 	p.openSelector(sel)
@@ -2763,6 +4058,18 @@ func (p *parser) parseSelStmt(backrefOk bool) *ast.SelStmt {
 	return sel
 }
 
+// mergeSelector replaces the selector of stmt with the merged
+// interpolated selector s
+func (p *parser) mergeSelector(stmt *ast.SelStmt, s string) {
+	sel, err := reparseSelector(s)
+	if err != nil {
+		p.error(stmt.Name.Pos(), err.Error())
+		return
+	}
+	stmt.Sel = sel.Sel
+	stmt.Resolved = sel.Resolved
+}
+
 // reparseSelector starts an entirely new scanner/parser to generate an ast for
 // This is entirely overkill and stupid, but interpolation support
 // is not at a place where selectors can support them without a
@@ -2829,6 +4136,11 @@ func (p *parser) parseSel() ast.Expr {
 		x := p.parseInterp()
 		p.resolveInterp(p.topScope, x)
 		return x
+	case token.SELECTOR:
+		// Scanner starts a new selector after interpolation, the
+		// text that follows belongs to this one
+		p.next()
+		return p.parseSel()
 	default:
 		log.Fatalf("unsupported sel type %s:%q\n", p.tok, p.lit)
 	}
@@ -2939,153 +4251,182 @@ func (p *parser) parseIncludeSpecFn(doc *ast.CommentGroup, keyword token.Token,
 	return p.parseIncludeSpec(!p.inMixin)
 }
 
-func sigPosition(pos int, list []*ast.Field, isVdc bool) (*ast.Field, error) {
-	l := len(list)
-	switch {
-	case pos < l-1:
-		fallthrough
-	case pos == l-1 && !isVdc:
-		return list[pos], nil
-	}
-
-	if !isVdc {
-		return nil, errors.New("variable outside bounds of field signature")
+// spreadIdent returns the variable of an argument passed with ...
+// ie. @include foo($args...)
+func spreadIdent(x ast.Expr) (*ast.Ident, bool) {
+	ident, ok := x.(*ast.Ident)
+	if !ok || !strings.HasSuffix(ident.Name, "...") {
+		return nil, false
 	}
-
-	// nil indicates multiple Fields apply
-	return nil, nil
+	return ast.NewIdent(strings.TrimSuffix(ident.Name, "...")), true
 }
 
-// processFuncArgs walks through the arguments declaring each signature
-// in the provided scope
-func (p *parser) processFuncArgs(scope *ast.Scope, signature *ast.FieldList, arguments *ast.FieldList) {
-	// assert(p.topScope == scope, "Invalid function argument scope")
-	var sigs []*ast.Ident
-
-	toDeclare := make(map[*ast.Ident]interface{})
-
-	var isVariadic bool
-	// Process the signature and defaults, toDeclaring the defaults
-	for i, sig := range signature.List {
-		var key *ast.Ident
-		fmt.Printf("typ... %T % #v %t\n", sig.Type, sig.Type, isVariadic)
-
-		// Convert ident or basiclit to ident
-		switch v := sig.Type.(type) {
-		default:
-			log.Fatalf("unsupported sig type % #v\n", v)
-		case *ast.Ident:
-			if isVariadic {
-				log.Fatal("only last argument can be variadic")
-			}
-			if strings.HasSuffix(v.Name, "...") {
-				v.Name = strings.TrimSuffix(v.Name, "...")
-				isVariadic = true
+// callArgs evaluates the arguments of a call in p.topScope. Arguments
+// passed with ... are spread, lists become positional arguments and
+// maps become keyword arguments.
+func (p *parser) callArgs(args []ast.Expr) ([]ast.Expr, []*ast.KeyValueExpr, error) {
+	var (
+		list []ast.Expr
+		kws  []*ast.KeyValueExpr
+	)
+	for _, arg := range args {
+		if ident, ok := spreadIdent(arg); ok {
+			p.tryResolve(ident, false)
+			if ident.Obj == nil {
+				return nil, nil, fmt.Errorf("Undefined variable: \"%s\".",
+					ident.Name)
 			}
-			field, err := sigPosition(i, signature.List, isVariadic)
-			if err != nil {
-				log.Fatalf("failed to process arguments: %s", err)
+			var m *ast.MapLit
+			switch v := funcValue(ident).(type) {
+			case *ast.ListLit:
+				list = append(list, v.Value...)
+				m = v.Keywords
+			case *ast.MapLit:
+				m = v
+			default:
+				list = append(list, v)
 			}
-
-			if field == nil {
+			if m == nil {
 				continue
 			}
-			key = v
-		case *ast.KeyValueExpr:
-			var val interface{}
-			// Default arg!
-			key = v.Key.(*ast.Ident)
-			// Set default value, if found
-			switch vv := v.Value.(type) {
-			case nil:
-			case *ast.BasicLit:
-				val = vv
-				// p.declare(val, nil, scope, ast.Var, ident)
-			case *ast.Ident:
-				p.resolve(vv)
-				// TODO: this may need to recursively search for BasicLit
-				val = vv.Obj.Decl
-			default:
-				log.Fatalf("unsupported default value % #v\n", vv)
+			for _, kv := range m.Elts {
+				key, err := calc.Resolve(kv.Key, true)
+				if err != nil {
+					return nil, nil, err
+				}
+				kws = append(kws, &ast.KeyValueExpr{
+					Key:   ast.NewIdent("$" + key.Value),
+					Colon: kv.Colon,
+					Value: kv.Value,
+				})
 			}
-			toDeclare[key] = val
+			continue
+		}
+		x, err := p.evalExpr(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if kv, ok := x.(*ast.KeyValueExpr); ok {
+			kws = append(kws, funcArg(kv).(*ast.KeyValueExpr))
+			continue
+		}
+		list = append(list, funcValue(x))
+	}
+	return list, kws, nil
+}
+
+// processFuncArgs declares the parameters of signature in scope.
+// Positional arguments bind in order and keyword arguments by name,
+// parameters left over take their default. A variable argument,
+// $args..., collects the remaining arguments and keywords.
+func (p *parser) processFuncArgs(scope *ast.Scope, signature *ast.FieldList, args []ast.Expr, kws []*ast.KeyValueExpr) error {
+	var (
+		params   []*ast.Ident
+		defaults []ast.Expr
+		rest     *ast.Ident
+	)
+	for i, field := range signature.List {
+		switch v := field.Type.(type) {
+		case *ast.Ident:
+			if strings.HasSuffix(v.Name, "...") {
+				if i < len(signature.List)-1 {
+					return errors.New("Only the last argument may be variable.")
+				}
+				v.Name = strings.TrimSuffix(v.Name, "...")
+				rest = v
+				continue
+			}
+			params = append(params, v)
+			defaults = append(defaults, nil)
+		case *ast.KeyValueExpr:
+			params = append(params, v.Key.(*ast.Ident))
+			defaults = append(defaults, v.Value)
+		default:
+			return fmt.Errorf("unsupported parameter % #v", v)
 		}
-		// Preserve sig
-		sigs = append(sigs, key)
 	}
 
-	// Hold variadic arguments, saving to a list
-	var lastArg []ast.Expr
+	if len(args) > len(params) && rest == nil {
+		return fmt.Errorf("Only %d argument(s) allowed, but %d were passed.",
+			len(params), len(args))
+	}
+	vals := make([]ast.Expr, len(params))
+	copy(vals, args)
 
-	// Now walk through passed arguments and toDeclare finding the
-	// appropriate matching arg
-	if arguments != nil {
-		for i, arg := range arguments.List {
-			var ident *ast.Ident
-			if i < len(sigs) {
-				ident = sigs[i]
-			}
+	var restKws []*ast.KeyValueExpr
+	for _, kw := range kws {
+		name := kw.Key.(*ast.Ident).Name
+		i := 0
+		for i < len(params) && params[i].Name != name {
+			i++
+		}
+		switch {
+		case i < len(params) && vals[i] != nil:
+			return fmt.Errorf("Argument %s was passed both by position and by name.", name)
+		case i < len(params):
+			vals[i] = kw.Value
+		case rest != nil:
+			restKws = append(restKws, kw)
+		default:
+			return fmt.Errorf("No argument named %s.", name)
+		}
+	}
 
-			var val interface{}
-			switch v := arg.Type.(type) {
-			case *ast.BasicLit:
-				val = &ast.AssignStmt{
-					Lhs:    []ast.Expr{ident},
-					TokPos: arg.Pos(),
-					Rhs:    []ast.Expr{v},
-				}
-			case *ast.Ident:
-				p.resolve(v)
-				val = v.Obj.Decl
-			case *ast.KeyValueExpr:
-				ident = v.Key.(*ast.Ident)
-				val = v.Value
-				if valdent, ok := val.(*ast.Ident); ok {
-					p.resolve(valdent)
-					val = valdent.Obj.Decl
-				}
-			}
-			if val == nil {
-				fmt.Printf("skipped argument %s\n", sigs[i])
-				continue
+	// Parameters are declared in order, so defaults may refer to the
+	// parameters before them
+	for i, ident := range params {
+		x := vals[i]
+		if x == nil {
+			if defaults[i] == nil {
+				return fmt.Errorf("Missing argument %s.", ident.Name)
 			}
-
-			if isVariadic && i >= len(sigs)-1 {
-				// these fucking assignstmt need to go away
-				v := val
-				if ass, ok := v.(*ast.AssignStmt); ok {
-					v = ass.Rhs[0]
-				}
-				lastArg = append(lastArg, v.(ast.Expr))
-				continue
+			y, err := p.evalExpr(ast.ExprCopy(defaults[i]))
+			if err != nil {
+				return err
 			}
-			toDeclare[ident] = val
+			x = funcValue(y)
 		}
+		p.declareArg(scope, ident, x)
 	}
 
-	if len(lastArg) > 0 {
-		ident := signature.List[len(signature.List)-1].Type.(*ast.Ident)
-		ident.Name = strings.TrimSuffix(ident.Name, "...")
-
-		list := p.listFromExprs(lastArg, true, true)
-		ass := &ast.AssignStmt{
-			Lhs:    []ast.Expr{ident},
-			TokPos: ident.Pos(),
-			Rhs:    []ast.Expr{list},
+	if rest == nil {
+		return nil
+	}
+	list := &ast.ListLit{
+		ValuePos: rest.Pos(),
+		EndPos:   rest.End(),
+		Comma:    true,
+	}
+	if len(args) > len(params) {
+		list.Value = args[len(params):]
+	}
+	if len(restKws) > 0 {
+		list.Keywords = &ast.MapLit{}
+		for _, kw := range restKws {
+			name := kw.Key.(*ast.Ident).Name
+			list.Keywords.Elts = append(list.Keywords.Elts, &ast.KeyValueExpr{
+				Key: &ast.BasicLit{
+					Kind:     token.STRING,
+					ValuePos: kw.Key.Pos(),
+					Value:    strings.TrimPrefix(name, "$"),
+				},
+				Colon: kw.Colon,
+				Value: kw.Value,
+			})
 		}
-		fmt.Println("declaring...", ident, ass)
-		p.declare(ass, nil, scope, ast.Var, ident)
-		fmt.Println("done decl")
 	}
+	p.declareArg(scope, rest, list)
+	return nil
+}
 
-	for k, v := range toDeclare {
-		if k == nil {
-			fmt.Printf("FIXME: nil declaration...", k)
-			astPrint(v)
-			continue
-		}
-		p.declare(v, nil, scope, ast.Var, k)
+// declareArg declares the parameter ident with the value x
+func (p *parser) declareArg(scope *ast.Scope, ident *ast.Ident, x ast.Expr) {
+	ass := &ast.AssignStmt{
+		Lhs:    []ast.Expr{ident},
+		TokPos: ident.Pos(),
+		Rhs:    []ast.Expr{x},
 	}
+	p.declare(ass, nil, scope, ast.Var, ident)
 }
 
 // walks through statements resolving them with the provided
@@ -3098,21 +4439,53 @@ func (p *parser) resolveStmts(scope *ast.Scope, stmts []ast.Stmt) []ast.Stmt {
 
 	ret := make([]ast.Stmt, 0, len(stmts))
 
+	from := 0
 	for i := range stmts {
+		// messages of the functions the last statement called
+		// stay in front of it
+		ret = p.insertPending(ret, from)
+		from = len(ret)
 		switch decl := stmts[i].(type) {
 		case *ast.DeclStmt:
 			p.resolveDecl(scope, decl)
 			stmts[i] = decl
 		case *ast.AssignStmt:
+			// copied values have not been resolved
+			for j, x := range decl.Rhs {
+				x, err := p.resolveCall(x)
+				if err != nil {
+					p.errorAt(decl.Rhs[j].Pos(), err)
+					continue
+				}
+				// Store the result, so variables updated in a loop
+				// do not refer to their previous value
+				if bin, ok := x.(*ast.BinaryExpr); ok {
+					if lit, err := calc.Resolve(bin, false); err == nil {
+						x = lit
+					}
+				}
+				decl.Rhs[j] = x
+			}
 			p.shortVarDecl(decl, decl.Lhs)
 		case *ast.CommStmt:
 		case *ast.EachStmt:
 			p.resolveEachStmt(scope, decl)
+		case *ast.ForStmt:
+			p.resolveForStmt(scope, decl)
+		case *ast.WhileStmt:
+			p.resolveWhileStmt(scope, decl)
 		case *ast.IncludeStmt:
 			p.resolveIncludeSpec(decl.Spec)
 		case *ast.SelStmt:
-			if len(p.sels) > 0 {
-				decl.Parent = p.sels[len(p.sels)-1]
+			decl.Parent = p.selParent(decl.Sel)
+			if len(decl.Raw) > 0 {
+				for _, x := range decl.Raw {
+					if itp, ok := x.(*ast.Interp); ok {
+						p.resolveInterp(scope, itp)
+					}
+				}
+				s, _ := itpMerge(decl.Raw)
+				p.mergeSelector(decl, s)
 			}
 			decl.Resolve(Globalfset)
 			p.openSelector(decl)
@@ -3127,6 +4500,38 @@ func (p *parser) resolveStmts(scope *ast.Scope, stmts []ast.Stmt) []ast.Stmt {
 			continue
 		case *ast.ReturnStmt:
 			// TODO: something to do here?
+		case *ast.ExtendStmt:
+			// Extends are collected by the compiler
+		case *ast.DebugStmt:
+			// Messages are printed by the compiler, only store the
+			// value of this copy
+			msg, err := p.message(decl.X)
+			if err != nil {
+				p.error(decl.X.Pos(), err.Error())
+				continue
+			}
+			decl.X = &ast.BasicLit{
+				ValuePos: decl.X.Pos(),
+				Kind:     token.STRING,
+				Value:    msg,
+			}
+		case *ast.AtRootStmt:
+			if decl.Sel != nil && len(p.sels) > 0 {
+				decl.Sel = p.sels[len(p.sels)-1].Resolved
+			}
+			lev, queries := p.rootLev, p.queries
+			if decl.Excludes("rule") {
+				p.rootLev = len(p.sels) + 1
+			}
+			if decl.Excludes("media") {
+				p.queries = nil
+			}
+			decl.Body.List = p.resolveStmts(ast.NewScope(scope), decl.Body.List)
+			p.rootLev, p.queries = lev, queries
+		case *ast.DirectiveStmt:
+			p.resolveDirectiveStmt(scope, decl)
+		case *ast.MediaStmt:
+			p.resolveMediaStmt(scope, decl)
 		case *ast.BlockStmt:
 			list := p.resolveStmts(scope, decl.List)
 			ret = append(ret, list...)
@@ -3136,7 +4541,48 @@ func (p *parser) resolveStmts(scope *ast.Scope, stmts []ast.Stmt) []ast.Stmt {
 		ret = append(ret, stmts[i])
 	}
 
-	return ret
+	return p.insertPending(ret, from)
+}
+
+// insertPending inserts the messages of the functions called since
+// the last insert into list at i
+func (p *parser) insertPending(list []ast.Stmt, i int) []ast.Stmt {
+	if len(p.pending) == 0 {
+		return list
+	}
+	// loop bodies run from the copies of the outermost loop, the
+	// messages of parsing them are dropped
+	if p.loops > 0 {
+		p.pending = nil
+		return list
+	}
+	msgs := make([]ast.Stmt, 0, len(p.pending)+len(list)-i)
+	for _, stmt := range p.pending {
+		msgs = append(msgs, stmt)
+	}
+	p.pending = nil
+	return append(list[:i], append(msgs, list[i:]...)...)
+}
+
+// resolveMediaStmt resolves the query and body of a copied @media
+func (p *parser) resolveMediaStmt(scope *ast.Scope, stmt *ast.MediaStmt) {
+	p.resolveMediaQuery(stmt)
+	p.mediaSel(stmt)
+	queries := p.queries
+	p.queries = stmt.Queries
+	stmt.Body.List = p.resolveStmts(ast.NewScope(scope), stmt.Body.List)
+	p.queries = queries
+}
+
+// resolveDirectiveStmt resolves the prelude and body of a copied
+// directive
+func (p *parser) resolveDirectiveStmt(scope *ast.Scope, stmt *ast.DirectiveStmt) {
+	p.resolveDirectiveParams(stmt)
+	p.directiveSel(stmt)
+	if stmt.Body == nil {
+		return
+	}
+	stmt.Body.List = p.resolveStmts(ast.NewScope(scope), stmt.Body.List)
 }
 
 func (p *parser) resolveExpr(scope *ast.Scope, expr ast.Expr) (out []*ast.BasicLit) {
@@ -3150,7 +4596,12 @@ func (p *parser) resolveExpr(scope *ast.Scope, expr ast.Expr) (out []*ast.BasicL
 		out = append(out, v)
 	case *ast.CallExpr:
 		x, _ := p.resolveCall(v)
-		out = append(out, x.(*ast.BasicLit))
+		if lit, ok := x.(*ast.BasicLit); ok {
+			out = append(out, lit)
+			break
+		}
+		// Functions may return lists ie. map-get($map, key)
+		out = append(out, p.resolveExpr(scope, x)...)
 	case *ast.Interp:
 		p.resolveInterp(scope, v)
 		fmt.Println("resolved...", v.Obj.Decl.(*ast.BasicLit))
@@ -3162,11 +4613,42 @@ func (p *parser) resolveExpr(scope *ast.Scope, expr ast.Expr) (out []*ast.BasicL
 		}
 		assert(v.Obj == nil, "statement had previous value, was it copied correctly?")
 		p.resolve(v)
+		if v.Obj == unresolved && isVar(v.Name) && p.memberError(v.Name) != "" {
+			// reported by resolve
+			break
+		}
 		out = basicLitFromIdent(v)
 	case *ast.ListLit:
 		for _, x := range v.Value {
 			out = append(out, p.resolveExpr(scope, x)...)
 		}
+	case *ast.MapLit:
+		// maps are not values of CSS ie. the result of keywords(),
+		// resolveDecl reports them
+	case *ast.StringExpr:
+		x, err := p.evalExpr(v)
+		if err != nil {
+			p.error(v.Pos(), err.Error())
+			break
+		}
+		lit, err := calc.Resolve(x, false)
+		if err != nil {
+			p.error(v.Pos(), err.Error())
+			break
+		}
+		out = append(out, lit)
+	case *ast.BinaryExpr:
+		x, err := p.resolveCall(v)
+		if err != nil {
+			p.errorAt(v.Pos(), err)
+			break
+		}
+		lit, err := calc.Resolve(x, false)
+		if err != nil {
+			p.error(v.Pos(), err.Error())
+			break
+		}
+		out = append(out, lit)
 	default:
 		panic(fmt.Errorf("unsupported expr % #v", v))
 	}
@@ -3191,11 +4673,14 @@ func (p *parser) resolveDecl(scope *ast.Scope, decl *ast.DeclStmt) {
 					val := sv.Values[i]
 					fmt.Printf("% #v\n", val)
 					lits = append(lits, p.resolveExpr(scope, val)...)
+					p.mapValue(val)
 				}
 				sv.Values = make([]ast.Expr, len(lits))
 				for i := range lits {
 					sv.Values[i] = lits[i]
 				}
+			case *ast.ImportSpec:
+				// the imported declarations follow the spec
 			default:
 				log.Fatalf("spec not supported % #v\n", v)
 			}
@@ -3205,6 +4690,36 @@ func (p *parser) resolveDecl(scope *ast.Scope, decl *ast.DeclStmt) {
 	}
 }
 
+// mapValue reports x if it is a map, maps are not values of CSS
+func (p *parser) mapValue(x ast.Expr) {
+	var m *ast.MapLit
+	for m == nil {
+		switch v := x.(type) {
+		case *ast.MapLit:
+			m = v
+		case *ast.CallExpr:
+			x = v.Resolved
+		case *ast.Ident:
+			if v.Obj == nil {
+				return
+			}
+			assign, ok := v.Obj.Decl.(*ast.AssignStmt)
+			if !ok || len(assign.Rhs) != 1 {
+				return
+			}
+			x = assign.Rhs[0]
+		default:
+			return
+		}
+	}
+	lit, err := calc.Resolve(m, false)
+	if err != nil {
+		p.error(m.Pos(), err.Error())
+		return
+	}
+	p.error(m.Pos(), lit.Value+" isn't a valid CSS value.")
+}
+
 // TODO: delete this, calc.Resolve can do it
 // basicLitFromIdent recursively resolves an Ident until a
 // basic lit is uncovered.
@@ -3230,6 +4745,10 @@ func basicLitFromIdent(ident *ast.Ident) (lit []*ast.BasicLit) {
 				var err error
 				lit, err = calc.Resolve(rtyp, rtyp.Paren)
 				assert(err == nil, "calc resolve failed")
+			case *ast.MapLit, *ast.BinaryExpr:
+				var err error
+				lit, err = calc.Resolve(rtyp, false)
+				assert(err == nil, "calc resolve failed")
 			default:
 				log.Fatalf("illegal Rhs expr % #v\n", rtyp)
 			}
@@ -3261,48 +4780,347 @@ func joinLits(a []*ast.BasicLit, sep string) string {
 	return strings.Join(s, sep)
 }
 
+// resolveFuncDecl calls a @function. The body runs statement by
+// statement until @return is found.
 func (p *parser) resolveFuncDecl(scope *ast.Scope, call *ast.CallExpr) (ast.Expr, error) {
-	ident := call.Fun.(*ast.Ident)
-
+	// Fun holds the result once called, look the function up again
+	ident := ast.NewIdent(call.Fun.(*ast.Ident).Name)
 	p.tryResolve(ident, false)
+	if msg := p.memberError(ident.Name); ident.Obj == nil && msg != "" {
+		return nil, &raisedError{Globalfset.Position(call.Fun.Pos()), msg}
+	}
 	assert(ident.Obj != nil, "failed to locate function: "+ident.Name)
-	args := call.Args
-	fnDecl := ident.Obj.Decl.(*ast.FuncDecl)
+	fnDecl, ok := ident.Obj.Decl.(*ast.FuncDecl)
+	if !ok || fnDecl.Tok != token.FUNC {
+		return nil, fmt.Errorf("%s is not a function", ident.Name)
+	}
 
-	// setup parameters within a new scope
-	// Walk through all statements performing a copy of each
-	list := fnDecl.Body.List
-	stmts := make([]ast.Stmt, 0, len(list))
+	args, kws, err := p.callArgs(call.Args)
+	if err != nil {
+		return nil, err
+	}
+	copyparams := ast.FieldListCopy(fnDecl.Type.Params)
 
-	for i := range list {
-		if list[i] != nil {
-			stmt := ast.StmtCopy(list[i])
-			stmts = append(stmts, stmt)
-		} else {
-			fmt.Println("it is nil", i)
+	// Functions see global variables of their module and their
+	// arguments, not the variables of the caller
+	oldScope := p.topScope
+	p.topScope = ast.NewScope(p.moduleScope(ident.Obj))
+	defer func() { p.topScope = oldScope }()
+
+	err = p.processFuncArgs(p.topScope, copyparams, args, kws)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := p.execFunc(fnDecl.Body.List)
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return nil, fmt.Errorf("Function %s finished without @return",
+			ident.Name)
+	}
+	return ret, nil
+}
+
+// funcArg prepares an evaluated argument for processFuncArgs
+func funcArg(x ast.Expr) ast.Expr {
+	if kv, ok := x.(*ast.KeyValueExpr); ok {
+		return &ast.KeyValueExpr{
+			Key:   ast.IdentCopy(kv.Key.(*ast.Ident)),
+			Colon: kv.Colon,
+			Value: funcValue(kv.Value),
 		}
 	}
-	copyparams := ast.FieldListCopy(fnDecl.Type.Params)
-	fields := make([]*ast.Field, 0, len(args))
-	for _, arg := range args {
-		fields = append(fields, &ast.Field{
-			Type: arg,
-		})
+	return funcValue(x)
+}
+
+// funcValue dereferences variables and calls until the value they
+// refer to is found
+func funcValue(x ast.Expr) ast.Expr {
+	for {
+		switch v := x.(type) {
+		case *ast.Ident:
+			if v.Obj == nil {
+				return v
+			}
+			ass, ok := v.Obj.Decl.(*ast.AssignStmt)
+			if !ok || len(ass.Rhs) != 1 {
+				return v
+			}
+			x = ass.Rhs[0]
+		case *ast.CallExpr:
+			if v.Resolved == nil {
+				return v
+			}
+			x = v.Resolved
+		default:
+			return x
+		}
 	}
-	copyargs := ast.FieldListCopy(&ast.FieldList{List: fields})
-	// All the identifiers within this list need to be re-resolved
-	// with the args passed in the include
-	p.openScope()
-	p.processFuncArgs(scope, copyparams, copyargs)
-	stmts = p.resolveStmts(scope, stmts)
-	p.closeScope()
-	// The last statement should be @return
-	ret, ok := stmts[len(stmts)-1].(*ast.ReturnStmt)
-	if !ok {
-		return nil, errors.New("failed to locate return statement")
+}
+
+// execFunc runs the statements of a function body in p.topScope. The
+// value of the first @return found is returned, nil if there is none.
+// Statements are copied before they are run, so loops can run the same
+// body many times.
+func (p *parser) execFunc(list []ast.Stmt) (ast.Expr, error) {
+	for _, stmt := range list {
+		switch v := ast.StmtCopy(stmt).(type) {
+		case *ast.AssignStmt:
+			for i := range v.Rhs {
+				x, err := p.evalExpr(v.Rhs[i])
+				if err != nil {
+					return nil, err
+				}
+				v.Rhs[i] = funcValue(x)
+			}
+			p.shortVarDecl(v, v.Lhs)
+		case *ast.ReturnStmt:
+			results := make([]ast.Expr, len(v.Results))
+			for i := range v.Results {
+				x, err := p.evalExpr(v.Results[i])
+				if err != nil {
+					return nil, err
+				}
+				results[i] = funcValue(x)
+			}
+			if len(results) == 1 {
+				return results[0], nil
+			}
+			return p.listFromExprs(results, true, false), nil
+		case *ast.IfStmt:
+			ok, err := p.evalCond(v.Cond)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				if ret, err := p.execFunc(v.Body.List); ret != nil || err != nil {
+					return ret, err
+				}
+				continue
+			}
+			switch el := v.Else.(type) {
+			case *ast.BlockStmt:
+				if ret, err := p.execFunc(el.List); ret != nil || err != nil {
+					return ret, err
+				}
+			case *ast.IfStmt:
+				if ret, err := p.execFunc([]ast.Stmt{el}); ret != nil || err != nil {
+					return ret, err
+				}
+			}
+		case *ast.EachStmt:
+			itrs := append([]*ast.Ident{v.X}, v.Vars...)
+			for _, x := range p.expandList(v.List) {
+				vals := p.destructure(x, len(itrs))
+				for i, itr := range itrs {
+					lits := p.resolveExpr(p.topScope, vals[i])
+					rhs := make([]ast.Expr, len(lits))
+					for i := range lits {
+						rhs[i] = lits[i]
+					}
+					p.assignIterator(itr, rhs...)
+				}
+				if ret, err := p.execFunc(v.Body.List); ret != nil || err != nil {
+					return ret, err
+				}
+			}
+		case *ast.ForStmt:
+			values, _, err := p.forRange(v)
+			if err != nil {
+				return nil, err
+			}
+			for _, lit := range values {
+				p.assignIterator(v.X, lit)
+				if ret, err := p.execFunc(v.Body.List); ret != nil || err != nil {
+					return ret, err
+				}
+			}
+		case *ast.WhileStmt:
+			for n := 0; ; n++ {
+				// the copy is resolved against the current values
+				ok, err := p.evalCond(ast.ExprCopy(v.Cond))
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					break
+				}
+				if n == p.maxIterations {
+					return nil, fmt.Errorf("@while exceeded %d iterations",
+						p.maxIterations)
+				}
+				if ret, err := p.execFunc(v.Body.List); ret != nil || err != nil {
+					return ret, err
+				}
+			}
+		case *ast.DebugStmt:
+			msg, err := p.message(v.X)
+			if err != nil {
+				return nil, err
+			}
+			if v.Tok == token.ERROR {
+				return nil, &raisedError{Globalfset.Position(v.Pos()), msg}
+			}
+			// The compiler prints the message before the statement
+			// calling the function, in order with the other messages
+			p.pending = append(p.pending, &ast.DebugStmt{
+				TokPos: v.TokPos,
+				Tok:    v.Tok,
+				X: &ast.BasicLit{
+					ValuePos: v.X.Pos(),
+					Kind:     token.STRING,
+					Value:    msg,
+				},
+			})
+		case *ast.CommStmt, *ast.EmptyStmt:
+		default:
+			return nil, errors.New("Functions can only contain variable declarations and control directives.")
+		}
+	}
+	return nil, nil
+}
+
+// assignIterator sets the iterator of a loop in p.topScope
+func (p *parser) assignIterator(itr *ast.Ident, rhs ...ast.Expr) {
+	r := ast.NewIdent(itr.Name)
+	ass := &ast.AssignStmt{
+		Lhs:    []ast.Expr{r},
+		TokPos: itr.Pos(),
+		Rhs:    rhs,
 	}
+	p.declare(ass, nil, p.topScope, ast.Var, r)
+}
 
-	return p.listFromExprs(ret.Results, false, false), nil
+// evalCond evaluates the condition of @if or @while
+func (p *parser) evalCond(cond ast.Expr) (bool, error) {
+	x, err := p.evalExpr(cond)
+	if err != nil {
+		return false, err
+	}
+	lit, err := calc.Resolve(x, true)
+	if err != nil {
+		return false, err
+	}
+	return calc.Truthy(lit), nil
+}
+
+// evalExpr resolves the variables of x in p.topScope and evaluates
+// calls and arithmetic found in it.
+func (p *parser) evalExpr(x ast.Expr) (ast.Expr, error) {
+	switch v := x.(type) {
+	case *ast.Ident:
+		if v.Obj == nil && isVar(v.Name) {
+			p.tryResolve(v, false)
+			if msg := p.memberError(v.Name); v.Obj == nil && msg != "" {
+				return nil, errors.New(msg)
+			}
+			if v.Obj == nil {
+				return nil, fmt.Errorf("Undefined variable: \"%s\".", v.Name)
+			}
+		}
+	case *ast.CallExpr:
+		if v.Resolved != nil {
+			break
+		}
+		// if() evaluates only the branch it selects
+		for i := 0; i < len(v.Args) && !isIf(v); i++ {
+			if _, ok := spreadIdent(v.Args[i]); ok {
+				continue
+			}
+			arg, err := p.evalExpr(v.Args[i])
+			if err != nil {
+				return nil, err
+			}
+			v.Args[i] = arg
+		}
+		res, err := evaluateCall(p, p.topScope, v)
+		if err != nil {
+			return nil, err
+		}
+		v.Resolved = res
+	case *ast.BinaryExpr:
+		l, err := p.evalExpr(v.X)
+		if err != nil {
+			return nil, err
+		}
+		r, err := p.evalExpr(v.Y)
+		if err != nil {
+			return nil, err
+		}
+		v.X, v.Y = l, r
+		return calc.Resolve(v, false)
+	case *ast.UnaryExpr:
+		y, err := p.evalExpr(v.X)
+		if err != nil {
+			return nil, err
+		}
+		lit, err := calc.Resolve(y, true)
+		if err != nil {
+			return nil, err
+		}
+		switch v.Op {
+		case token.SUB:
+			val := "-" + lit.Value
+			if strings.HasPrefix(lit.Value, "-") {
+				val = lit.Value[1:]
+			}
+			return &ast.BasicLit{
+				ValuePos: v.Pos(),
+				Kind:     lit.Kind,
+				Value:    val,
+			}, nil
+		case token.ADD:
+			return lit, nil
+		case token.NOT:
+			return &ast.BasicLit{
+				ValuePos: v.Pos(),
+				Kind:     token.STRING,
+				Value:    strconv.FormatBool(!calc.Truthy(lit)),
+			}, nil
+		}
+	case *ast.ListLit:
+		for i := range v.Value {
+			y, err := p.evalExpr(v.Value[i])
+			if err != nil {
+				return nil, err
+			}
+			v.Value[i] = funcValue(y)
+		}
+	case *ast.MapLit:
+		for _, kv := range v.Elts {
+			y, err := p.evalExpr(kv.Value)
+			if err != nil {
+				return nil, err
+			}
+			kv.Value = funcValue(y)
+		}
+	case *ast.KeyValueExpr:
+		y, err := p.evalExpr(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		v.Value = y
+	case *ast.Interp:
+		// variables of the interpolation are those of the function
+		for i := range v.X {
+			y, err := p.evalExpr(v.X[i])
+			if err != nil {
+				return nil, err
+			}
+			v.X[i] = y
+		}
+		p.resolveInterp(p.topScope, v)
+	case *ast.StringExpr:
+		for i := range v.List {
+			y, err := p.evalExpr(v.List[i])
+			if err != nil {
+				return nil, err
+			}
+			v.List[i] = y
+		}
+	}
+	return x, nil
 }
 
 func (p *parser) resolveIncludeSpec(spec *ast.IncludeSpec) {
@@ -3317,26 +5135,43 @@ func (p *parser) resolveIncludeSpec(spec *ast.IncludeSpec) {
 			ident.Name,
 			p.topScope,
 		))
-	args := spec.Params
-	fnDecl := ident.Obj.Decl.(*ast.FuncDecl)
+	// a mixin including itself by importing its own file is still
+	// being declared
+	fnDecl, ok := ident.Obj.Decl.(*ast.FuncDecl)
+	if !ok {
+		p.error(ident.Pos(), "undefined mixin: "+ident.Name)
+		return
+	}
 
 	// Walk through all statements performing a copy of each
-	list := fnDecl.Body.List
-
-	for i := range list {
-		stmt := ast.StmtCopy(list[i])
-		spec.List = append(spec.List, stmt)
+	for _, stmt := range fnDecl.Body.List {
+		spec.List = append(spec.List, ast.StmtCopy(stmt))
 	}
 
 	copyparams := ast.FieldListCopy(fnDecl.Type.Params)
-	copyargs := ast.FieldListCopy(args)
+	var list []ast.Expr
+	for _, field := range ast.FieldListCopy(spec.Params).List {
+		list = append(list, field.Type)
+	}
 
 	// All the identifiers within this list need to be re-resolved
 	// with the args passed in the include
+	args, kws, err := p.callArgs(list)
+	// mixins of other modules see the global variables of their module
+	oldScope := p.topScope
+	if scope := p.moduleScope(ident.Obj); scope != p.pkgScope {
+		p.topScope = scope
+	}
 	p.openScope()
-	p.processFuncArgs(p.topScope, copyparams, copyargs)
+	defer func() { p.topScope = oldScope }()
+	if err == nil {
+		err = p.processFuncArgs(p.topScope, copyparams, args, kws)
+	}
+	if err != nil {
+		p.error(spec.Name.Pos(), err.Error())
+		return
+	}
 	spec.List = p.resolveStmts(p.topScope, spec.List)
-	p.closeScope()
 }
 
 // @include foo(second, third);
@@ -3352,7 +5187,14 @@ func (p *parser) parseIncludeSpec(doResolve bool) *ast.IncludeSpec {
 	// @include hux;   // basiclit
 	ident := ast.ToIdent(expr)
 	assert(ident.Name != "_", "invalid include identifier")
-	args, _ := p.parseSignature(p.topScope)
+	var args *ast.FieldList
+	if p.tok == token.LPAREN {
+		lparen, list, rparen := p.parseArgs()
+		args = &ast.FieldList{Opening: lparen, Closing: rparen}
+		for _, x := range list {
+			args.List = append(args.List, &ast.Field{Type: x})
+		}
+	}
 	spec := &ast.IncludeSpec{
 		Name:   ident,
 		Params: args,
@@ -3403,8 +5245,9 @@ func (p *parser) parseMixinDecl() *ast.FuncDecl {
 		Body: body,
 	}
 
-	// Mixins are available to everything parsed from here on out
-	p.declare(decl, nil, p.pkgScope, ast.Fun, ident)
+	// Mixins are available to everything parsed from here on out in
+	// the block declaring them, like variables of nested imports
+	p.declare(decl, p.pkgScope, p.topScope, ast.Fun, ident)
 
 	return decl
 }
@@ -3451,7 +5294,7 @@ func (p *parser) parseFuncDecl() *ast.FuncDecl {
 		},
 		Body: body,
 	}
-	p.declare(decl, nil, p.topScope, ast.Var, ident)
+	p.declare(decl, p.pkgScope, p.topScope, ast.Var, ident)
 	return decl
 }
 
@@ -3478,12 +5321,28 @@ func (p *parser) parseDecl(sync func(*parser)) ast.Decl {
 		return p.parseRuleDecl()
 	case token.IMPORT:
 		// s := &ast.DeclStmt{Decl: p.parse}
-		return p.parseGenDecl("", token.IMPORT, p.parseImportSpec)
+		return p.parseImportDecl()
+	case token.USE:
+		return p.parseUseDecl()
+	case token.FORWARD:
+		return p.parseForwardDecl()
 	case token.MIXIN:
 		return p.parseMixinDecl()
 	case token.IF:
 		stmt := p.parseIfStmt()
 		return &ast.IfDecl{IfStmt: stmt}
+	case token.EACH:
+		return &ast.EachDecl{EachStmt: p.parseEachStmt()}
+	case token.FOR:
+		return &ast.ForDecl{ForStmt: p.parseForStmt()}
+	case token.WHILE:
+		return &ast.WhileDecl{WhileStmt: p.parseWhileStmt()}
+	case token.DEBUG, token.WARN, token.ERROR:
+		return &ast.DebugDecl{DebugStmt: p.parseDebugStmt()}
+	case token.DIRECTIVE:
+		return &ast.DirectiveDecl{DirectiveStmt: p.parseDirectiveStmt()}
+	case token.MEDIA:
+		return &ast.MediaDecl{MediaStmt: p.parseMediaStmt()}
 	default:
 		pos := p.pos
 		p.errorExpected(pos, "declaration")
@@ -3515,6 +5374,7 @@ func (p *parser) parseFile() *ast.File {
 
 	p.openScope()
 	p.pkgScope = p.topScope
+	p.module = newModule(p.file.Name())
 	var decls []ast.Decl
 	// Bypass importing for now
 	// if p.mode&PackageClauseOnly == 0 {
@@ -3526,7 +5386,20 @@ func (p *parser) parseFile() *ast.File {
 	if p.mode&ImportsOnly == 0 {
 		// rest of package body
 		for p.tok != token.EOF {
-			decls = append(decls, p.parseDecl(syncDecl))
+			decl := p.parseDecl(syncDecl)
+			// messages of the functions decl called stay in front
+			// of it
+			for _, stmt := range p.pending {
+				decls = append(decls, &ast.DebugDecl{DebugStmt: stmt})
+			}
+			p.pending = nil
+			decls = append(decls, decl)
+			// @at-root leaving a top level @media follows it
+			for _, stmt := range p.escaped {
+				decls = append(decls, &ast.AtRootDecl{AtRootStmt: stmt.(*ast.AtRootStmt)})
+			}
+			p.escaped = nil
+			p.leaveModules()
 		}
 	}
 
@@ -3555,6 +5428,8 @@ func (p *parser) parseFile() *ast.File {
 		Decls:      decls,
 		Scope:      p.pkgScope,
 		Imports:    p.imports,
+		Deps:       p.deps,
+		Globs:      p.globs,
 		Unresolved: p.unresolved[0:i],
 		Comments:   p.comments,
 	}
@@ -0,0 +1,267 @@
+package scanner
+
+import (
+	"strings"
+
+	"github.com/wellington/sass/token"
+)
+
+// indentedStmt is a statement of the indented syntax, it spans from
+// line first to line last
+type indentedStmt struct {
+	first, last int
+	indent      int
+}
+
+// Indented translates src written in the indented syntax of .sass files
+// to SCSS. Blocks are opened by indenting the following lines and
+// statements end at the end of the line. Braces and semicolons are
+// added at the end of lines, so the result keeps the line numbers of
+// the .sass source.
+//
+// The shorthands =mixin, +include and :property value are expanded and
+// unquoted @import paths are quoted. Lines dedented to an indentation
+// not used by an enclosing block are reported to err, if not nil.
+func Indented(src []byte, err ErrorHandler) []byte {
+	lines := strings.Split(string(stripCR(src)), "\n")
+
+	var stmts []indentedStmt
+	comment := -1 // indent of the enclosing comment
+	loud := -1    // last line of an unclosed /* comment
+	closeLoud := func() {
+		if loud >= 0 {
+			lines[loud] = strings.TrimRight(lines[loud], " \t") + " */"
+			loud = -1
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		indent := indentWidth(lines[i])
+		body := strings.TrimSpace(lines[i])
+		if len(body) == 0 {
+			continue
+		}
+		// lines indented below a comment continue it
+		if comment >= 0 {
+			if indent > comment {
+				if loud >= 0 {
+					loud = i
+					if strings.Contains(body, "*/") {
+						loud = -1
+					}
+				} else {
+					lines[i] = ""
+				}
+				continue
+			}
+			closeLoud()
+			comment = -1
+		}
+		// silent comments are not printed, they are removed
+		if strings.HasPrefix(body, "//") {
+			lines[i] = ""
+			comment = indent
+			continue
+		}
+		if strings.HasPrefix(body, "/*") {
+			comment = indent
+			if !strings.Contains(body, "*/") {
+				loud = i
+			}
+			continue
+		}
+
+		// selector lists end in a comma and parentheses may
+		// span lines
+		stmt := indentedStmt{first: i, indent: indent}
+		depth := parenDepth(lines[i])
+		for i+1 < len(lines) && (depth > 0 ||
+			strings.HasSuffix(lineCode(lines[i]), ",")) {
+			i++
+			depth += parenDepth(lines[i])
+		}
+		stmt.last = i
+		stmts = append(stmts, stmt)
+	}
+	closeLoud()
+
+	var open []int   // indent of the statements with an open block
+	var levels []int // indent of the statements of the open blocks
+	if len(stmts) > 0 {
+		levels = append(levels, stmts[0].indent)
+	}
+	for k, stmt := range stmts {
+		next := -1
+		if k+1 < len(stmts) {
+			next = stmts[k+1].indent
+		}
+		block := next > stmt.indent
+		line := lines[stmt.first]
+		lines[stmt.first] = line[:stmt.indent] +
+			expandIndented(strings.TrimSpace(line), block)
+
+		end := ";"
+		if block {
+			end = " {"
+			open = append(open, stmt.indent)
+			levels = append(levels, next)
+		}
+		for len(open) > 0 && open[len(open)-1] >= next {
+			end += " }"
+			open = open[:len(open)-1]
+			levels = levels[:len(levels)-1]
+		}
+		if next >= 0 && next != levels[len(levels)-1] && err != nil {
+			err(token.Position{
+				Line:   stmts[k+1].first + 1,
+				Column: next + 1,
+			}, "inconsistent indentation")
+		}
+		lines[stmt.last] = lineEnd(lines[stmt.last], end)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// indentWidth counts the spaces and tabs at the start of line
+func indentWidth(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// lineComment returns the offset of a trailing // comment in line
+// or -1. Comments start the line or follow whitespace, so urls
+// like http://a.com are not comments.
+func lineComment(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '/' && i+1 < len(line) && line[i+1] == '/':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// lineCode returns line without the trailing comment and whitespace
+func lineCode(line string) string {
+	if i := lineComment(line); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// lineEnd appends end to the code of line, before any comment
+func lineEnd(line, end string) string {
+	code := lineCode(line)
+	if i := lineComment(line); i >= 0 {
+		return code + end + " " + line[i:]
+	}
+	return code + end
+}
+
+// parenDepth returns the number of parentheses opened but not
+// closed in line
+func parenDepth(line string) int {
+	var depth int
+	var quote byte
+	line = lineCode(line)
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		}
+	}
+	return depth
+}
+
+// expandIndented rewrites the shorthands of the indented syntax in the
+// statement body to SCSS. block is set when the statement has a block.
+func expandIndented(body string, block bool) string {
+	switch {
+	case strings.HasPrefix(body, "="):
+		return mixinParams("@mixin " + strings.TrimSpace(body[1:]))
+	case strings.HasPrefix(body, "@mixin "):
+		return mixinParams(body)
+	case len(body) > 1 && body[0] == '+' && isLetter(rune(body[1])):
+		return "@include " + body[1:]
+	case strings.HasPrefix(body, "@import "):
+		return indentedImport(body[len("@import "):])
+	case !block && len(body) > 1 && body[0] == ':' && isLetter(rune(body[1])):
+		// :property value
+		if i := strings.IndexAny(body, " \t"); i > 0 {
+			return body[1:i] + ":" + body[i:]
+		}
+	}
+	return body
+}
+
+// mixinParams adds the empty parameter list to mixins declared
+// without one
+func mixinParams(decl string) string {
+	code := lineCode(decl)
+	if strings.Contains(code, "(") {
+		return decl
+	}
+	return code + "()" + decl[len(code):]
+}
+
+// indentedImport splits a list of imports into statements and quotes
+// bare paths ie. @import a, b
+func indentedImport(paths string) string {
+	var imps []string
+	code := lineCode(paths)
+	for _, path := range splitTop(code) {
+		path = strings.TrimSpace(path)
+		if len(path) == 0 {
+			continue
+		}
+		if path[0] != '"' && path[0] != '\'' &&
+			!strings.HasPrefix(path, "url(") {
+			path = `"` + path + `"`
+		}
+		imps = append(imps, "@import "+path)
+	}
+	return strings.Join(imps, "; ") + paths[len(code):]
+}
+
+// splitTop splits s at commas outside of quotes and parentheses
+func splitTop(s string) []string {
+	var parts []string
+	var quote byte
+	var depth, start int
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case ch == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
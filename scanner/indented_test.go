@@ -0,0 +1,40 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/wellington/sass/token"
+)
+
+func TestIndented(t *testing.T) {
+	table := []struct {
+		in, out string
+	}{
+		{"a\n  b: c\n", "a {\n  b: c; }\n"},
+		{"a\n  b\n    c: d\ne\n  f: g", "a {\n  b {\n    c: d; } }\ne {\n  f: g; }"},
+		{"a,\nb\n  c: d // e", "a,\nb {\n  c: d; } // e"},
+		{"$a: (1,\n  2)\n", "$a: (1,\n  2);\n"},
+		{"// a\n  b\nc\n  d: e", "\n\nc {\n  d: e; }"},
+		{"/* a\n  b\nc\n  d: e", "/* a\n  b */\nc {\n  d: e; }"},
+		{"=m\n  a: b\n", "@mixin m() {\n  a: b; }\n"},
+		{"=m($a)\n  a: $a\n", "@mixin m($a) {\n  a: $a; }\n"},
+		{"a\n  +m(1)\n", "a {\n  @include m(1); }\n"},
+		{"a\n  :b c\n", "a {\n  b: c; }\n"},
+		{"@import a, \"b\", url(c)\n", "@import \"a\"; @import \"b\"; @import url(c);\n"},
+		{"a\n  b: url(http://c.com)\n", "a {\n  b: url(http://c.com); }\n"},
+	}
+	for _, tt := range table {
+		if out := string(Indented([]byte(tt.in), nil)); out != tt.out {
+			t.Errorf("got:\n%q\nwanted:\n%q", out, tt.out)
+		}
+	}
+}
+
+func TestIndented_dedent(t *testing.T) {
+	var lines []int
+	eh := func(pos token.Position, msg string) { lines = append(lines, pos.Line) }
+	Indented([]byte("a\n    b: c\n  d: e\nf\n  g\n    h: i\n  j: k\n"), eh)
+	if len(lines) != 1 || lines[0] != 3 {
+		t.Fatalf("got errors on lines %v wanted [3]", lines)
+	}
+}
@@ -94,6 +94,20 @@ type Scanner struct {
 	// it resets at '{'
 	inDirective bool
 
+	// inMedia scans the tokens of a media query, it resets at '{'
+	inMedia bool
+
+	// inImport scans the paths of @import, importPath is set after
+	// each path. A media query may follow the last one. importInterp
+	// counts the interpolations open in a quoted path.
+	inImport     bool
+	importPath   bool
+	importInterp int
+
+	// inModule scans the prelude of @use and @forward, it resets at
+	// the configuration of @use or the end of the statement
+	inModule bool
+
 	// inQuote is a hack to apply different text rules whilst
 	// inside quotes
 	inQuote rune
@@ -247,6 +261,15 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 		// If the queue is empty, do nothing
 	}
 
+	if s.inImport {
+		return s.scanImport()
+	}
+	if s.inModule {
+		return s.scanModule()
+	}
+	if s.inMedia {
+		return s.scanMedia()
+	}
 	//pos, tok, lit = s.scan(-1)
 	return s.scan()
 }
@@ -278,6 +301,9 @@ scanAgain:
 		fallthrough
 	case ch == '~':
 		fallthrough
+	case ch == '%' && s.rdOffset < len(s.src) && isLetter(rune(s.src[s.rdOffset])):
+		// placeholder selector %foo
+		fallthrough
 	case isLetter(ch):
 		// Scan until encountering {};
 		// selector: { termination
@@ -307,7 +333,7 @@ bypassSelector:
 		lit = ""
 		tok = token.EOF
 	case '$':
-		lit = s.scanText(s.offset-1, 0, false, isText)
+		lit = s.scanVar(s.offset - 1)
 		tok = token.VAR
 	case '#':
 		// color:    #fff[000]
@@ -327,7 +353,7 @@ bypassSelector:
 			tok = token.COLON
 		}
 	case '-':
-		if isLetter(s.ch) {
+		if isLetter(s.ch) || s.ch == '-' {
 			pos, tok, lit = s.scanRule(offs)
 		} else {
 			tok = token.SUB
@@ -393,6 +419,7 @@ bypassSelector:
 		// !global !default
 		if s.offset-offs > 1 {
 			tok = token.STRING
+			lit = string(s.src[offs:s.offset])
 		} else {
 			tok = s.switch2(token.NOT, token.NEQ)
 		}
@@ -712,7 +739,7 @@ func (s *Scanner) selLoop(offs int) (pos token.Pos, tok token.Token, lit string)
 	switch ch := s.ch; {
 	case ch == '{':
 		tok = token.ILLEGAL
-	case ch == '#' || ch == '.':
+	case ch == '#' || ch == '.' || ch == '%':
 		s.next()
 		if !isLetter(s.ch) {
 			if s.ch != '{' {
@@ -726,13 +753,14 @@ func (s *Scanner) selLoop(offs int) (pos token.Pos, tok token.Token, lit string)
 			}
 		}
 		fallthrough
-	// Standard selectors ie. #id .cla div
-	case isLetter(ch):
+	// Standard selectors ie. #id .cla div, -suffix continues
+	// an interpolated selector
+	case isLetter(ch) || ch == '-':
 		s.next()
 		s.skipWhitespace()
 		tok = token.STRING
-		for isLetter(s.ch) || isDigit(s.ch) ||
-			s.ch == '.' || s.ch == '#' {
+		for isLetter(s.ch) || isDigit(s.ch) || s.ch == '-' ||
+			s.ch == '.' || s.ch == '#' || s.ch == '%' {
 			ch = s.ch
 			s.next()
 			if ch == '#' && s.ch == '{' {
@@ -747,6 +775,13 @@ func (s *Scanner) selLoop(offs int) (pos token.Pos, tok token.Token, lit string)
 			}
 		}
 		lit = string(bytes.TrimSpace(s.src[offs:s.offset]))
+	// Keyframe selectors ie. 10%
+	case isDigit(ch):
+		for isDigit(s.ch) || s.ch == '.' || s.ch == '%' {
+			s.next()
+		}
+		tok = token.STRING
+		lit = string(s.src[offs:s.offset])
 	default:
 		s.next()
 		switch ch {
@@ -761,8 +796,10 @@ func (s *Scanner) selLoop(offs int) (pos token.Pos, tok token.Token, lit string)
 			tok = token.TIL
 		case '&':
 			tok = token.AND
-			for IsSymbol(s.ch) || isLetter(s.ch) || isDigit(s.ch) ||
-				s.ch == '.' || s.ch == '#' {
+			// suffixes ie. &-b, &--mod and &__el belong to the parent,
+			// a comma starts the next selector of the group
+			for (IsSymbol(s.ch) && s.ch != ',') || isLetter(s.ch) || isDigit(s.ch) ||
+				s.ch == '.' || s.ch == '#' || s.ch == '-' {
 				s.next()
 			}
 			lit = string(bytes.TrimSpace(s.src[offs:s.offset]))
@@ -834,14 +871,21 @@ func (s *Scanner) scanInterpBlock() bool {
 }
 
 // syntax of @each easily fools scanDelim
-// @each {variable} in {list/map}
+// @each {variable}[, {variable}...] in {list/map}
 func (s *Scanner) scanEach(offs int) {
-	// queue the iterator, look for in and parse the list/map
+	// queue the iterators, look for in and parse the list/map
 	s.next()
 	s.push(s.scan())
+	s.skipWhitespace()
+	for s.ch == ',' {
+		s.push(s.file.Pos(s.offset), token.COMMA, "")
+		s.next()
+		s.skipWhitespace()
+		s.push(s.scan())
+		s.skipWhitespace()
+	}
 
 	// find 'in'
-	s.skipWhitespace()
 	inoffs := s.offset
 	for isText(s.ch, false) {
 		s.next()
@@ -853,6 +897,8 @@ func (s *Scanner) scanEach(offs int) {
 	}
 	s.push(s.file.Pos(inoffs), token.STRING, inlit)
 	s.skipWhitespace()
+	// parens do not end the list ie. (a b), c
+	s.inDirective = true
 	// list is surrounded with params, we're done here
 	if s.ch == '(' {
 		return
@@ -862,6 +908,25 @@ func (s *Scanner) scanEach(offs int) {
 	return
 }
 
+func (s *Scanner) scanFor(offs int) {
+	// queue the iterator, look for from and parse the bounds
+	s.next()
+	s.push(s.scan())
+
+	s.skipWhitespace()
+	fromoffs := s.offset
+	for isText(s.ch, false) {
+		s.next()
+	}
+	fromlit := string(s.src[fromoffs:s.offset])
+	if fromlit != "from" {
+		s.error(fromoffs, "from must be present in @for statement")
+	}
+	s.push(s.file.Pos(fromoffs), token.STRING, fromlit)
+	// bounds are values, through and to are scanned as strings
+	s.inParams = true
+}
+
 func (s *Scanner) scanInterp(offs int) (pos token.Pos, tok token.Token, lit string) {
 	if s.ch != '#' {
 		return
@@ -941,6 +1006,24 @@ func (s *Scanner) scanText(offs int, end rune, whitespace bool, fn func(rune, bo
 	return ss
 }
 
+// scanVar scans a variable name. A hyphen is part of the name when it
+// is followed by another name character, $a-b is a single variable
+// while $a-$b and $a - $b are subtraction.
+func (s *Scanner) scanVar(offs int) string {
+	for {
+		s.scanText(offs, 0, false, isText)
+		if s.ch != '-' || s.rdOffset >= len(s.src) {
+			break
+		}
+		r := rune(s.src[s.rdOffset])
+		if !isLetter(r) && !isDigit(r) && r != '-' {
+			break
+		}
+		s.next()
+	}
+	return string(s.src[offs:s.offset])
+}
+
 func (s *Scanner) scanRGB(pos int) (tok token.Token, lit string) {
 	tok = token.COLOR
 	offs := pos
@@ -1003,9 +1086,13 @@ func (s *Scanner) scanDirective() (tok token.Token, lit string) {
 		s.backup()
 	case "@for":
 		tok = token.FOR
+		s.scanFor(s.offset)
 	case "@each":
 		tok = token.EACH
 		s.scanEach(s.offset)
+	case "@while":
+		tok = token.WHILE
+		s.inDirective = true
 	case "@include":
 		tok = token.INCLUDE
 	case "@function":
@@ -1016,12 +1103,24 @@ func (s *Scanner) scanDirective() (tok token.Token, lit string) {
 		tok = token.RETURN
 	case "@import":
 		tok = token.IMPORT
+		s.inImport = true
+		s.importPath = false
+	case "@use":
+		tok = token.USE
+		s.inModule = true
+	case "@forward":
+		tok = token.FORWARD
+		s.inModule = true
 	case "@media":
 		tok = token.MEDIA
+		s.inMedia = true
+	case "@extend":
+		tok = token.EXTEND
 		s.skipWhitespace()
-		// media queries have a lot of runes, eat until the first {
+		// extend targets are selectors, eat until the end of the
+		// statement
 		offs := s.offset
-		for s.ch != '{' {
+		for s.ch != ';' && s.ch != '}' && s.ch != -1 {
 			s.next()
 		}
 		lit := s.src[offs:s.offset]
@@ -1030,8 +1129,6 @@ func (s *Scanner) scanDirective() (tok token.Token, lit string) {
 			tok: token.STRING,
 			lit: string(bytes.TrimSpace(lit)),
 		}
-	case "@extend":
-		tok = token.EXTEND
 	case "@at-root":
 		tok = token.ATROOT
 	case "@debug":
@@ -1040,11 +1137,269 @@ func (s *Scanner) scanDirective() (tok token.Token, lit string) {
 		tok = token.WARN
 	case "@error":
 		tok = token.ERROR
+	default:
+		// CSS at-rules are passed through ie. @font-face
+		if len(lit) > 1 {
+			tok = token.DIRECTIVE
+			s.scanPrelude()
+		}
 	}
 
 	return
 }
 
+// scanMedia scans the next token of a media query. Words are strings,
+// feature values are expressions ie. print and (min-width: $a + 1px)
+func (s *Scanner) scanMedia() (pos token.Pos, tok token.Token, lit string) {
+	s.skipWhitespace()
+	pos = s.file.Pos(s.offset)
+	offs := s.offset
+	ch := s.ch
+	switch {
+	case isLetter(ch) || ch == '-' && s.peekLetter():
+		for isLetter(s.ch) || isDigit(s.ch) || s.ch == '-' {
+			s.next()
+		}
+		if s.ch == '(' {
+			// function calls pass through as written
+			// ie. (min-width: calc(10px + 2em))
+			s.skipParens()
+		}
+		lit = string(s.src[offs:s.offset])
+		tok = token.STRING
+		return
+	case isDigit(ch) || ch == '.':
+		tok, lit = s.scanNumber(false)
+		utok, ulit := s.scanUnit()
+		if utok != token.ILLEGAL {
+			tok = utok
+			lit = lit + ulit
+		}
+		return
+	case ch == '{' || ch == ';' || ch == -1:
+		// the query is finished
+		s.inMedia = false
+		return s.scan()
+	}
+
+	s.next()
+	switch ch {
+	case '$':
+		lit = s.scanVar(offs)
+		tok = token.VAR
+	case '#':
+		if s.ch != '{' {
+			tok, lit = s.scanColor()
+			break
+		}
+		s.next()
+		tok, lit = token.INTERP, "#{"
+	case '}':
+		tok = token.RBRACE
+	case '(':
+		tok = token.LPAREN
+	case ')':
+		tok = token.RPAREN
+	case ',':
+		tok = token.COMMA
+	case ':':
+		tok = token.COLON
+	case '+':
+		tok = token.ADD
+	case '-':
+		tok = token.SUB
+	case '*':
+		tok = token.MUL
+	case '/':
+		tok = token.QUO
+	case '<':
+		tok = s.switch2(token.LSS, token.LEQ)
+	case '>':
+		tok = s.switch2(token.GTR, token.GEQ)
+	case '=':
+		tok = token.EQL
+	default:
+		s.error(offs, "unsupported character in media query: "+string(ch))
+		tok = token.ILLEGAL
+		lit = string(ch)
+	}
+	return
+}
+
+// skipParens moves past the parentheses starting at s.ch and the
+// parentheses nested in them, quoted parentheses are ignored
+func (s *Scanner) skipParens() {
+	var depth int
+	var quote rune
+	for s.ch != -1 {
+		ch := s.ch
+		s.next()
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// scanImport scans the paths of @import. Quoted paths and url() are
+// paths, the words following them are a media query.
+//
+// @import "a.css", url(b.css) screen;
+func (s *Scanner) scanImport() (pos token.Pos, tok token.Token, lit string) {
+	if s.inQuote != 0 {
+		// the path is text up to the quote or an interpolation,
+		// "**/*" holds no comment
+		s.skipWhitespace()
+		if s.importInterp == 0 && s.ch != s.inQuote &&
+			!(s.ch == '#' && s.rdOffset < len(s.src) &&
+				s.src[s.rdOffset] == '{') {
+			pos, tok, lit = s.scanQuoted(s.offset)
+			if tok == token.STRING {
+				return
+			}
+		}
+		pos, tok, lit = s.scan()
+		switch {
+		case tok == token.INTERP:
+			s.importInterp++
+		case tok == token.RBRACE && s.importInterp > 0:
+			s.importInterp--
+		}
+		s.importPath = s.inQuote == 0
+		return
+	}
+	s.skipWhitespace()
+	switch {
+	case s.ch == '"' || s.ch == '\'':
+		return s.scan()
+	case s.ch == ',':
+		s.importPath = false
+		return s.scan()
+	case s.ch == ';' || s.ch == '{' || s.ch == '}' || s.ch == -1:
+	case bytes.HasPrefix(s.src[s.offset:], []byte("url(")):
+		offs := s.offset
+		var quote rune
+		for s.ch != -1 && (s.ch != ')' || quote != 0) {
+			switch {
+			case s.ch == quote:
+				quote = 0
+			case quote == 0 && (s.ch == '"' || s.ch == '\''):
+				quote = s.ch
+			}
+			s.next()
+		}
+		if s.ch == -1 {
+			s.error(offs, "url not terminated")
+		} else {
+			s.next()
+		}
+		s.importPath = true
+		return s.file.Pos(offs), token.STRING, string(s.src[offs:s.offset])
+	case s.importPath:
+		s.inImport = false
+		s.inMedia = true
+		return s.scanMedia()
+	}
+	s.inImport = false
+	return s.scan()
+}
+
+// scanModule scans the prelude of @use and @forward. Keywords, names
+// and prefixes are strings.
+//
+// @use "a" as b with ($x: 1);
+// @forward "a" as a-* hide $x, y;
+func (s *Scanner) scanModule() (pos token.Pos, tok token.Token, lit string) {
+	if s.inQuote != 0 {
+		return s.scan()
+	}
+	s.skipWhitespace()
+	pos = s.file.Pos(s.offset)
+	offs := s.offset
+	switch {
+	case s.ch == '"' || s.ch == '\'':
+		return s.scan()
+	case s.ch == ',':
+		s.next()
+		return pos, token.COMMA, ""
+	case s.ch == '$' || isLetter(s.ch) || s.ch == '-' || s.ch == '*':
+		tok = token.STRING
+		if s.ch == '$' {
+			tok = token.VAR
+			s.next()
+		}
+		for isLetter(s.ch) || isDigit(s.ch) || s.ch == '-' ||
+			s.ch == '*' {
+			s.next()
+		}
+		return pos, tok, string(s.src[offs:s.offset])
+	}
+	s.inModule = false
+	return s.scan()
+}
+
+// peekLetter reports whether the next character is a letter
+func (s *Scanner) peekLetter() bool {
+	return s.rdOffset < len(s.src) && isLetter(rune(s.src[s.rdOffset]))
+}
+
+// scanPrelude queues the text between an at-rule and its block or
+// the end of the statement. Interpolations are queued as tokens.
+//
+// @keyframes #{$name} {
+func (s *Scanner) scanPrelude() {
+	s.skipWhitespace()
+	offs := s.offset
+	text := func() {
+		lit := bytes.TrimRight(s.src[offs:s.offset], " \t\r\n")
+		if len(lit) > 0 {
+			s.push(s.file.Pos(offs), token.STRING, string(lit))
+		}
+	}
+	for {
+		switch s.ch {
+		case -1, '{', ';', '}':
+			text()
+			return
+		case '"', '\'':
+			quote := s.ch
+			s.next()
+			for s.ch != quote && s.ch != '\n' && s.ch != -1 {
+				s.next()
+			}
+			s.next()
+		case '#':
+			s.next()
+			if s.ch != '{' {
+				continue
+			}
+			s.backup()
+			text()
+			pos, tok, lit := s.scanInterp(s.offset)
+			s.push(pos, tok, lit)
+			for tok != token.EOF && tok != token.RBRACE {
+				pos, tok, lit = s.scan()
+				s.push(pos, tok, lit)
+			}
+			s.skipWhitespace()
+			offs = s.offset
+		default:
+			s.next()
+		}
+	}
+}
+
 func (s *Scanner) scanRule(offs int) (pos token.Pos, tok token.Token, lit string) {
 	var interp bool
 ruleAgain:
@@ -1127,25 +1482,38 @@ func (s *Scanner) scanValue(offs int) (pos token.Pos, tok token.Token, lit strin
 
 	// lit = s.scanText(offs, 0, true, isText)
 	if s.offset > offs {
+		lit = string(s.src[offs:s.offset])
 		if tok == token.ILLEGAL {
 			tok = token.STRING
-			if s.src[offs] == '$' {
+			// variables of modules are namespaced ie. ns.$x
+			if s.src[offs] == '$' || isLetter(rune(s.src[offs])) &&
+				strings.Contains(lit, ".$") {
 				tok = token.VAR
 			}
 		}
-		lit = string(s.src[offs:s.offset])
 	}
 	return
 }
 
 func (s *Scanner) scanIdent(offs int) (pos token.Pos, tok token.Token, lit string) {
 	pos = s.file.Pos(offs)
-	for isLetter(s.ch) || isDigit(s.ch) || s.ch == '-' {
+	// members of modules are namespaced ie. math.unit()
+	for isLetter(s.ch) || isDigit(s.ch) || s.ch == '-' ||
+		s.ch == '.' && s.offset > offs && s.peekLetter() {
 		s.next()
 	}
 	lit = string(s.src[offs:s.offset])
-	if len(lit) > 0 {
-		tok = token.IDENT
+	if len(lit) == 0 {
+		return
+	}
+	tok = token.IDENT
+	// the prescan may have found the ( of a later call ie. f(a, g())
+	i := s.offset
+	for i < len(s.src) && isSpace(rune(s.src[i])) {
+		i++
+	}
+	if i == len(s.src) || s.src[i] != '(' {
+		tok = token.STRING
 	}
 	return
 }
@@ -1190,7 +1558,10 @@ func (s *Scanner) scanUnit() (token.Token, string) {
 	case "%":
 		tok = token.UPCT
 	default:
-		lit = ""
+		// units without math support ie. 1fr are kept as strings
+		if len(lit) > 0 {
+			tok = token.STRING
+		}
 	}
 
 	return tok, lit
@@ -135,7 +135,25 @@ func TestScan_directive(t *testing.T) {
 
 	testScan(t, []elt{
 		{token.MEDIA, "@media"},
-		{token.STRING, "print and (foo: 1 2 3), (bar: 3px hux(muz)), not screen"},
+		{token.STRING, "print"},
+		{token.STRING, "and"},
+		{token.LPAREN, "("},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.INT, "2"},
+		{token.INT, "3"},
+		{token.RPAREN, ")"},
+		{token.COMMA, ","},
+		{token.LPAREN, "("},
+		{token.STRING, "bar"},
+		{token.COLON, ":"},
+		{token.UPX, "3px"},
+		{token.STRING, "hux(muz)"},
+		{token.RPAREN, ")"},
+		{token.COMMA, ","},
+		{token.STRING, "not"},
+		{token.STRING, "screen"},
 		{token.LBRACE, "{"},
 	})
 
@@ -203,6 +221,74 @@ func TestScan_directive(t *testing.T) {
 		{token.STRING, "a"},
 		{token.LBRACE, "{"},
 	})
+
+	testScan(t, []elt{
+		{token.EACH, "@each"},
+		{token.VAR, "$k"},
+		{token.COMMA, ","},
+		{token.VAR, "$v"},
+		{token.STRING, "in"},
+		{token.LPAREN, "("},
+		{token.STRING, "a"},
+		{token.STRING, "b"},
+		{token.RPAREN, ")"},
+		{token.COMMA, ","},
+		{token.STRING, "c"},
+		{token.LBRACE, "{"},
+	})
+}
+
+func TestScan_for(t *testing.T) {
+	testScan(t, []elt{
+		{token.FOR, "@for"},
+		{token.VAR, "$i"},
+		{token.STRING, "from"},
+		{token.INT, "1"},
+		{token.STRING, "through"},
+		{token.VAR, "$n"},
+		{token.LBRACE, "{"},
+		{token.STRING, ".col-a"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.RBRACE, "}"},
+	})
+}
+
+func TestScan_while(t *testing.T) {
+	testScan(t, []elt{
+		{token.WHILE, "@while"},
+		{token.VAR, "$i"},
+		{token.GTR, ">"},
+		{token.INT, "0"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+	})
+}
+
+func TestScan_atroot(t *testing.T) {
+	testScan(t, []elt{
+		{token.ATROOT, "@at-root"},
+		{token.LPAREN, "("},
+		{token.RULE, "without"},
+		{token.COLON, ":"},
+		{token.STRING, "media"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+	})
+
+	oldWs := whitespace
+	defer func() {
+		whitespace = oldWs
+	}()
+	whitespace = ""
+	// BEM modifiers follow interpolation
+	testScanMap(t, "#{$b}--mod{", []elt{
+		{token.INTERP, "#{"},
+		{token.VAR, "$b"},
+		{token.RBRACE, "}"},
+		{token.STRING, "--mod"},
+		{token.LBRACE, "{"},
+	})
 }
 
 func TestScan_quotes(t *testing.T) {
@@ -335,14 +421,168 @@ func TestScan_nested(t *testing.T) {
 	})
 }
 
+func TestScan_placeholder(t *testing.T) {
+	testScan(t, []elt{
+		{token.STRING, "%foo"},
+		{token.LBRACE, "{"},
+		{token.STRING, "%bar.baz"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.RBRACE, "}"},
+	})
+}
+
 func TestScan_media(t *testing.T) {
 	testScan(t, []elt{
 		{token.MEDIA, "@media"},
-		{token.STRING, "print and (foo: 1 2 3)"},
+		{token.STRING, "print"},
+		{token.STRING, "and"},
+		{token.LPAREN, "("},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.INT, "2"},
+		{token.INT, "3"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+	})
+
+	testScan(t, []elt{
+		{token.MEDIA, "@media"},
+		{token.STRING, "screen"},
+		{token.STRING, "and"},
+		{token.LPAREN, "("},
+		{token.STRING, "min-width"},
+		{token.COLON, ":"},
+		{token.VAR, "$a"},
+		{token.ADD, "+"},
+		{token.UPX, "1px"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+	})
+
+	testScan(t, []elt{
+		{token.MEDIA, "@media"},
+		{token.INTERP, "#{"},
+		{token.VAR, "$query"},
+		{token.RBRACE, "}"},
 		{token.LBRACE, "{"},
 	})
 }
 
+func TestScan_import(t *testing.T) {
+	testScan(t, []elt{
+		{token.IMPORT, "@import"},
+		{token.QSTRING, `"`},
+		{token.STRING, "a.css"},
+		{token.QSTRING, `"`},
+		{token.COMMA, ","},
+		{token.STRING, `url("b")`},
+		{token.STRING, "screen"},
+		{token.STRING, "and"},
+		{token.LPAREN, "("},
+		{token.STRING, "color"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+	})
+
+	testScan(t, []elt{
+		{token.IMPORT, "@import"},
+		{token.STRING, "foo"},
+		{token.SEMICOLON, ";"},
+	})
+
+	testScan(t, []elt{
+		{token.IMPORT, "@import"},
+		{token.QSTRING, `"`},
+		{token.STRING, "**/*"},
+		{token.QSTRING, `"`},
+		{token.SEMICOLON, ";"},
+	})
+
+	testScan(t, []elt{
+		{token.IMPORT, "@import"},
+		{token.QSTRING, `"`},
+		{token.STRING, "a/"},
+		{token.INTERP, "#{"},
+		{token.VAR, "$x"},
+		{token.MUL, "*"},
+		{token.INT, "2"},
+		{token.RBRACE, "}"},
+		{token.STRING, "/**/*"},
+		{token.QSTRING, `"`},
+		{token.SEMICOLON, ";"},
+	})
+}
+
+func TestScan_module(t *testing.T) {
+	testScan(t, []elt{
+		{token.USE, "@use"},
+		{token.QSTRING, `"`},
+		{token.STRING, "lib"},
+		{token.QSTRING, `"`},
+		{token.STRING, "as"},
+		{token.STRING, "ns"},
+		{token.STRING, "with"},
+		{token.LPAREN, "("},
+		{token.VAR, "$a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+	})
+
+	testScan(t, []elt{
+		{token.FORWARD, "@forward"},
+		{token.QSTRING, `"`},
+		{token.STRING, "lib"},
+		{token.QSTRING, `"`},
+		{token.STRING, "as"},
+		{token.STRING, "p-*"},
+		{token.STRING, "show"},
+		{token.STRING, "x"},
+		{token.COMMA, ","},
+		{token.VAR, "$y"},
+		{token.SEMICOLON, ";"},
+	})
+
+	testScan(t, []elt{
+		{token.RULE, "a"},
+		{token.COLON, ":"},
+		{token.VAR, "ns.$x"},
+		{token.SEMICOLON, ";"},
+		{token.RULE, "b"},
+		{token.COLON, ":"},
+		{token.IDENT, "ns.fn"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+	})
+}
+
+func TestScan_atrule(t *testing.T) {
+	testScan(t, []elt{
+		{token.DIRECTIVE, "@supports"},
+		{token.STRING, "(display: grid) and (not (x: y))"},
+		{token.LBRACE, "{"},
+	})
+
+	testScan(t, []elt{
+		{token.DIRECTIVE, "@keyframes"},
+		{token.STRING, "spin-"},
+		{token.INTERP, "#{"},
+		{token.VAR, "$x"},
+		{token.RBRACE, "}"},
+		{token.LBRACE, "{"},
+	})
+
+	testScan(t, []elt{
+		{token.DIRECTIVE, "@charset"},
+		{token.STRING, `"UTF-8"`},
+		{token.SEMICOLON, ";"},
+	})
+}
+
 func TestScan_duel(t *testing.T) {
 	tokens := []byte(`$color;`)
 
@@ -416,6 +656,13 @@ func TestScan_value(t *testing.T) {
 		{token.RBRACE, "}"},
 	})
 
+	testScan(t, []elt{
+		{token.VAR, "$x"},
+		{token.COLON, ":"},
+		{token.STRING, "blue"},
+		{token.STRING, "!default"},
+		{token.SEMICOLON, ";"},
+	})
 }
 
 func TestScan_attr_sel_now(t *testing.T) {
@@ -515,6 +762,24 @@ func TestScan_func(t *testing.T) {
 		{token.VAR, "$number"},
 		{token.RPAREN, ")"},
 	})
+
+	testScan(t, []elt{
+		{token.IDENT, "if"},
+		{token.LPAREN, "("},
+		{token.STRING, "true"},
+		{token.COMMA, ","},
+		{token.VAR, "$if-true"},
+		{token.COMMA, ","},
+		{token.IDENT, "map-get"},
+		{token.LPAREN, "("},
+		{token.VAR, "$a"},
+		{token.SUB, "-"},
+		{token.VAR, "$b"},
+		{token.COMMA, ","},
+		{token.STRING, "c"},
+		{token.RPAREN, ")"},
+		{token.RPAREN, ")"},
+	})
 }
 
 func TestScan_unit(t *testing.T) {
@@ -523,6 +788,7 @@ func TestScan_unit(t *testing.T) {
 		{token.UEM, "5.1em"},
 		{token.UCM, "5.1cm"},
 		{token.UPCT, "3%"},
+		{token.STRING, "1fr"},
 		{token.SEMICOLON, ";"},
 	})
 
@@ -30,6 +30,7 @@ const (
 	ATTRIBUTE // [disabled] [type='button']
 	PSEUDO    // :first-child :nth-last-child
 	AND       // & backreference
+	NULL      // null
 	literal_end
 
 	cssnums_beg
@@ -128,6 +129,12 @@ const (
 	DEBUG  // @debug
 	WARN   // @warn
 	ERROR  // @error
+
+	// Modules
+	USE     // @use
+	FORWARD // @forward
+
+	DIRECTIVE // CSS at-rules ie. @font-face, @keyframes
 	keyword_end
 
 	CMDVAR
@@ -163,6 +170,7 @@ var Tokens = [...]string{
 	QSSTRING: `singlequote`,
 	COLOR:    "color",
 	INTERP:   "INTERPOLATION",
+	NULL:     "null",
 	// Selector tokens
 	ATTRIBUTE: "attribute",
 	// BACKREF: "&",
@@ -245,7 +253,7 @@ var Tokens = [...]string{
 	FUNC:    "@function",
 	MIXIN:   "@mixin",
 	RETURN:  "@return",
-	WHILE:   "$while",
+	WHILE:   "@while",
 
 	IMPORT: "@import",
 	MEDIA:  "@media",
@@ -255,6 +263,11 @@ var Tokens = [...]string{
 	WARN:   "@warn",
 	ERROR:  "@error",
 
+	USE:     "@use",
+	FORWARD: "@forward",
+
+	DIRECTIVE: "@directive",
+
 	BKND: "background",
 	FIN:  "FINISHED",
 }